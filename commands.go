@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/taiidani/achievements/internal/data"
+	"github.com/taiidani/achievements/internal/data/cache"
+	"github.com/taiidani/achievements/internal/data/history"
+	"github.com/taiidani/achievements/internal/steam"
+)
+
+const usage = `Usage: achievements [command]
+
+With no command, the webapp is served.
+
+Commands:
+  cache list [--pattern PATTERN]          List cached keys
+  cache get KEY                           Print the value cached under a key
+  cache delete KEY...                     Delete keys from the cache
+  cache stats                             Count cached keys per key family
+  cache flush --pattern PATTERN [--yes]   Delete every key matching a pattern,
+                                          other than achievement history
+  export [--format FORMAT] [--scope SCOPE] STEAMID
+                                          Print a user's achievements as csv,
+                                          json or ndjson, for "all" games or
+                                          a single "game:{id}"
+
+Patterns use "*" wildcards and are matched within CACHE_NAMESPACE, against
+keys such as "v2:player:*:games".
+`
+
+// runCommand executes the command line subcommand in args.
+func runCommand(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "cache":
+		return cacheCommand(ctx, os.Stdout, args[1:])
+	case "export":
+		return exportCommand(ctx, os.Stdout, args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	}
+
+	return fmt.Errorf("unknown command %q\n\n%s", args[0], usage)
+}
+
+// cacheCommand inspects and manages the cache, through the same backend that
+// the webapp uses.
+func cacheCommand(ctx context.Context, out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("a cache subcommand is required\n\n%s", usage)
+	}
+
+	if _, configured, _ := setupRedisOptions(); !configured {
+		return fmt.Errorf("no Redis configuration env vars set. The in-memory cache is only available to the webapp")
+	}
+
+	c, err := setupCache(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to set up cache: %w", err)
+	}
+
+	return runCacheCommand(ctx, out, c, args)
+}
+
+// runCacheCommand runs a cache subcommand against the given cache.
+func runCacheCommand(ctx context.Context, out io.Writer, c cache.Cache, args []string) error {
+	flags := flag.NewFlagSet("cache "+args[0], flag.ContinueOnError)
+	pattern := flags.String("pattern", "", `The "*" based pattern of keys to match`)
+	yes := flags.Bool("yes", false, "Confirm deletion, rather than listing the keys that would be deleted")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		if *pattern == "" {
+			*pattern = "*"
+		}
+
+		keys, err := namespaceKeys(ctx, c, *pattern)
+		if err != nil {
+			return err
+		}
+
+		slices.Sort(keys)
+		for _, key := range keys {
+			fmt.Fprintln(out, key)
+		}
+		return nil
+
+	case "get":
+		if flags.NArg() != 1 {
+			return fmt.Errorf("exactly one key is required")
+		}
+
+		key := flags.Arg(0)
+		if err := checkNamespaceKey(key); err != nil {
+			return err
+		}
+
+		// Entries are read raw, as they cannot all be decoded without their
+		// type
+		data := cache.Raw{}
+		if err := c.Get(ctx, key, &data); err != nil {
+			return fmt.Errorf("unable to get %q: %w", key, err)
+		}
+
+		entry, err := cache.Inspect(data)
+		if err != nil {
+			return fmt.Errorf("unable to decode %q: %w", key, err)
+		} else if entry.Codec == cache.CodecGob {
+			fmt.Fprintf(out, "%d byte %s entry, compressed with %s. Gob entries can only be decoded by the webapp\n", entry.Size, entry.Codec, entry.Compression)
+			return nil
+		}
+
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entry.Value)
+
+	case "delete":
+		if flags.NArg() == 0 {
+			return fmt.Errorf("at least one key is required")
+		}
+
+		// The same keys are protected as from flush
+		for _, key := range flags.Args() {
+			if err := checkNamespaceKey(key); err != nil {
+				return err
+			} else if isHistoryKey(key) {
+				return fmt.Errorf("%q holds achievement history, which cannot be fetched again", key)
+			}
+		}
+
+		if err := c.Delete(ctx, flags.Args()...); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Deleted %d key(s)\n", flags.NArg())
+		return nil
+
+	case "stats":
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FAMILY\tVERSION\tKEYS")
+
+		for _, family := range data.KeyFamilies() {
+			keys, err := c.Keys(ctx, family.Pattern())
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\tv%d\t%d\n", family.Name(), family.Version, len(keys))
+		}
+
+		all, err := namespaceKeys(ctx, c, "*")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "(all keys)\t\t%d\n", len(all))
+		return w.Flush()
+
+	case "flush":
+		if *pattern == "" {
+			return fmt.Errorf("--pattern is required. To flush every cached key, pass --pattern '*'")
+		}
+
+		keys, err := namespaceKeys(ctx, c, *pattern)
+		if err != nil {
+			return err
+		}
+
+		// History is kept alongside the cache, but cannot be fetched again
+		keys = slices.DeleteFunc(keys, isHistoryKey)
+
+		if !*yes {
+			slices.Sort(keys)
+			for _, key := range keys {
+				fmt.Fprintln(out, key)
+			}
+			fmt.Fprintf(out, "%d key(s) would be deleted. Re-run with --yes to delete them\n", len(keys))
+			return nil
+		}
+
+		if err := c.Delete(ctx, keys...); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Deleted %d key(s)\n", len(keys))
+		return nil
+	}
+
+	return fmt.Errorf("unknown cache subcommand %q\n\n%s", args[0], usage)
+}
+
+// namespaceKeys lists the keys matching the pattern within the cache
+// namespace, which is prefixed to the pattern. Keys of any namespace nested
+// within it are left out.
+func namespaceKeys(ctx context.Context, c cache.Cache, pattern string) ([]string, error) {
+	// Only "*" is understood by every cache, and the other Redis wildcards
+	// could match outside of the namespace
+	if strings.ContainsAny(pattern, `?[]\`) {
+		return nil, fmt.Errorf("pattern %q may only use \"*\" wildcards", pattern)
+	}
+
+	prefix := ""
+	if cache.Namespace != "" {
+		prefix = cache.Namespace + ":"
+	}
+	if strings.ContainsAny(prefix, `*?[]\`) {
+		return nil, fmt.Errorf("namespace %q contains wildcards, so its keys cannot be matched", cache.Namespace)
+	}
+
+	keys, err := c.Keys(ctx, prefix+pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(keys, func(key string) bool {
+		rest, _ := strings.CutPrefix(key, prefix)
+		return !versionedKey.MatchString(rest)
+	}), nil
+}
+
+// checkNamespaceKey returns an error unless the key was built by a
+// cache.KeyFamily within the cache namespace, and not within any namespace
+// nested in it.
+func checkNamespaceKey(key string) error {
+	rest, ok := key, true
+	if cache.Namespace != "" {
+		rest, ok = strings.CutPrefix(key, cache.Namespace+":")
+	}
+	if !ok || !versionedKey.MatchString(rest) {
+		return fmt.Errorf("%q is not a key within the namespace %q", key, cache.Namespace)
+	}
+	return nil
+}
+
+// versionedKey matches keys built by a cache.KeyFamily, once their namespace
+// has been removed.
+var versionedKey = regexp.MustCompile(`^v\d+:`)
+
+// isHistoryKey reports whether the key holds achievement history.
+func isHistoryKey(key string) bool {
+	for _, family := range history.KeyFamilies() {
+		if _, err := family.Parse(key); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// exportCommand prints a user's achievements in the same formats as the
+// webapp's export page. Steam data is read through the cache when Redis is
+// configured, and fetched from Steam otherwise.
+func exportCommand(ctx context.Context, out io.Writer, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", string(data.ExportCSV), "One of csv, json or ndjson")
+	scope := flags.String("scope", "all", `Either "all" or "game:{id}"`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("exactly one Steam ID is required\n\n%s", usage)
+	}
+
+	exportFormat, err := data.ParseExportFormat(*format)
+	if err != nil {
+		return err
+	}
+	exportScope, err := data.ParseExportScope(*scope)
+	if err != nil {
+		return err
+	}
+
+	c, err := setupCache(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to set up cache: %w", err)
+	}
+
+	backend := data.NewData(steam.NewClient(), c, nil)
+	export, err := backend.NewExport(ctx, flags.Arg(0), exportScope)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(out)
+	if err := export.Write(ctx, w, exportFormat); err != nil {
+		return err
+	}
+	return w.Flush()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+)
+
+func TestNamespaceKeys(t *testing.T) {
+	original := cache.Namespace
+	t.Cleanup(func() { cache.Namespace = original })
+	cache.Namespace = "prod"
+
+	c := cache.NewMemory()
+	ctx := context.Background()
+	for _, key := range []string{
+		"prod:v2:game:620:schema",
+		"prod:v1:history:{1}:events",
+		"prod:eu:v2:game:620:schema",
+		"staging:v2:game:620:schema",
+		"v2:game:620:schema",
+	} {
+		if err := c.Set(ctx, key, "value", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{pattern: "*", want: []string{"prod:v1:history:{1}:events", "prod:v2:game:620:schema"}},
+		{pattern: "v2:game:*", want: []string{"prod:v2:game:620:schema"}},
+		{pattern: "*:staging:*", want: []string{}},
+		{pattern: "v?:game:*", wantErr: true},
+		{pattern: "[ps]*", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got, err := namespaceKeys(ctx, c, tt.pattern)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsHistoryKey(t *testing.T) {
+	original := cache.Namespace
+	t.Cleanup(func() { cache.Namespace = original })
+	cache.Namespace = "prod"
+
+	tests := map[string]bool{
+		"prod:v1:history:{1}:events":             true,
+		"prod:v1:history:{1}:events:detected":    true,
+		"prod:v1:history:{1}:game:620:snapshot":  true,
+		"prod:v2:player:1:game:620:achievements": false,
+		"prod:v2:game:620:schema":                false,
+	}
+
+	for key, want := range tests {
+		if got := isHistoryKey(key); got != want {
+			t.Errorf("isHistoryKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestCacheGetDelete(t *testing.T) {
+	original := cache.Namespace
+	t.Cleanup(func() { cache.Namespace = original })
+	cache.Namespace = "prod"
+
+	c := cache.NewMemory()
+	ctx := context.Background()
+	keys := []string{
+		"prod:v2:game:620:schema",
+		"prod:v1:history:{1}:events",
+		"prod:eu:v2:game:620:schema",
+		"staging:v2:game:620:schema",
+	}
+	for _, key := range keys {
+		if err := c.Set(ctx, key, map[string]string{"name": "Portal 2"}, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{args: []string{"get", "prod:v2:game:620:schema"}},
+		{args: []string{"get", "prod:v2:game:570:schema"}, wantErr: true},
+		{args: []string{"get", "staging:v2:game:620:schema"}, wantErr: true},
+		{args: []string{"delete", "prod:v1:history:{1}:events"}, wantErr: true},
+		{args: []string{"delete", "prod:eu:v2:game:620:schema"}, wantErr: true},
+		{args: []string{"delete", "prod:v2:game:620:schema", "staging:v2:game:620:schema"}, wantErr: true},
+		{args: []string{"delete", "prod:v2:game:620:schema"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			out := &strings.Builder{}
+			err := runCacheCommand(ctx, out, c, tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %q, want an error", out.String())
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.args[0] == "get" && !strings.Contains(out.String(), "Portal 2") {
+				t.Errorf("got %q, want the entry's value", out.String())
+			}
+		})
+	}
+
+	// Only the deleted key is gone
+	for _, key := range keys {
+		ok, err := c.Has(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		} else if want := key != "prod:v2:game:620:schema"; ok != want {
+			t.Errorf("Has(%q) = %v, want %v", key, ok, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+)
+
+// activeTTL is how long a user is remembered after they were last seen. It
+// bounds how far back ActiveUsers can look.
+const activeTTL = time.Hour * 24 * 30
+
+// activeEvery throttles how often each user is marked as active by a replica.
+const activeEvery = time.Hour
+
+// markActive records that the user was seen, so that their data is kept up to
+// date in the background. Failures are logged, as this is only an optimization.
+func (d *Data) markActive(ctx context.Context, userID string) {
+	if last, ok := d.active.Load(userID); ok && time.Since(last.(time.Time)) < activeEvery {
+		return
+	}
+	d.active.Store(userID, time.Now())
+
+	if err := d.cache.Set(ctx, keyActiveUser.Key(userID), time.Now(), activeTTL); err != nil {
+		slog.Warn("Unable to mark user as active", "steam-id", userID, "error", err)
+	}
+}
+
+// ActiveUsers lists the Steam IDs of users seen within the given duration.
+func (d *Data) ActiveUsers(ctx context.Context, within time.Duration) ([]string, error) {
+	keys, err := d.cache.Keys(ctx, keyActiveUser.Pattern())
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	for _, key := range keys {
+		match, err := keyActiveUser.Parse(key)
+		if err != nil {
+			return ret, err
+		}
+
+		var seen time.Time
+		if err := d.cache.Get(ctx, key, &seen); errors.Is(err, cache.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return ret, err
+		}
+
+		if time.Since(seen) <= within {
+			ret = append(ret, match[0])
+		}
+	}
+
+	return ret, nil
+}
@@ -2,12 +2,47 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"time"
 )
 
+// ErrNotFound is returned by Get when the key is not present in the cache.
+var ErrNotFound = errors.New("key not found")
+
+// ErrUnavailable is returned by caches that cannot currently reach their
+// backend, rather than waiting on it.
+var ErrUnavailable = errors.New("cache unavailable")
+
 type Cache interface {
 	Get(context.Context, string, any) error
 	Set(context.Context, string, any, time.Duration) error
+	// SetNX sets the key only if it is not already present, reporting whether
+	// it was set. It is atomic across every replica sharing the cache.
+	SetNX(context.Context, string, any, time.Duration) (bool, error)
 	Has(context.Context, string) (bool, error)
 	Keys(context.Context, string) ([]string, error)
+	Delete(context.Context, ...string) error
+}
+
+// Notifier is implemented by caches that can broadcast messages to every
+// replica sharing them.
+type Notifier interface {
+	Publish(ctx context.Context, channel string, message string) error
+	// Subscribe delivers messages on the channel until the context is
+	// cancelled or the subscription is lost, at which point the returned
+	// channel is closed.
+	Subscribe(ctx context.Context, channel string) (<-chan string, error)
+}
+
+// Queue is implemented by caches that can hold lists of messages to be
+// processed in order, such as background jobs.
+type Queue interface {
+	// Push appends a message to the queue.
+	Push(ctx context.Context, queue string, message string) error
+	// Pop removes and returns the oldest message in the queue, waiting up to
+	// timeout for one to arrive. ErrNotFound is returned if none did.
+	Pop(ctx context.Context, queue string, timeout time.Duration) (string, error)
+	// Pending returns every message waiting in the queue, without removing
+	// them.
+	Pending(ctx context.Context, queue string) ([]string, error)
 }
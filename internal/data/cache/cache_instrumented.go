@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Instrumented decorates a Cache, counting hits, misses, sets, errors and the
+// time spent on each operation per key family.
+type Instrumented struct {
+	next     Cache
+	families []instrumentedFamily
+	stats    map[string]*FamilyStats
+	mx       sync.Mutex
+}
+
+var _ Cache = &Instrumented{}
+
+// instrumentedFamily holds a family's pattern and name, which are built once
+// rather than for every operation.
+type instrumentedFamily struct {
+	pattern string
+	name    string
+}
+
+// FamilyStats records the usage of a single key family.
+type FamilyStats struct {
+	Hits   uint64
+	Misses uint64
+	Sets   uint64
+	Errors uint64
+	// Operations counts every call made against the family, alongside the
+	// total time that they took.
+	Operations uint64
+	Latency    time.Duration
+}
+
+// FamilyOther collects keys that do not belong to any known family.
+const FamilyOther = "other"
+
+// NewInstrumented wraps the cache, attributing each key to the first of the
+// given families that it belongs to. The Namespace must be set beforehand.
+func NewInstrumented(next Cache, families []KeyFamily) *Instrumented {
+	ret := &Instrumented{
+		next:  next,
+		stats: map[string]*FamilyStats{},
+	}
+	for _, family := range families {
+		ret.families = append(ret.families, instrumentedFamily{pattern: family.Pattern(), name: family.Name()})
+	}
+	return ret
+}
+
+func (c *Instrumented) Get(ctx context.Context, key string, val any) error {
+	start := time.Now()
+	err := c.next.Get(ctx, key, val)
+
+	c.record(c.family(key), time.Since(start), func(s *FamilyStats) {
+		switch {
+		case err == nil:
+			s.Hits++
+		case errors.Is(err, ErrNotFound):
+			s.Misses++
+		default:
+			s.Errors++
+		}
+	})
+	return err
+}
+
+func (c *Instrumented) Set(ctx context.Context, key string, val any, ttl time.Duration) error {
+	start := time.Now()
+	err := c.next.Set(ctx, key, val, ttl)
+
+	c.record(c.family(key), time.Since(start), func(s *FamilyStats) {
+		if err != nil {
+			s.Errors++
+		} else {
+			s.Sets++
+		}
+	})
+	return err
+}
+
+func (c *Instrumented) SetNX(ctx context.Context, key string, val any, ttl time.Duration) (bool, error) {
+	start := time.Now()
+	ok, err := c.next.SetNX(ctx, key, val, ttl)
+
+	c.record(c.family(key), time.Since(start), func(s *FamilyStats) {
+		if err != nil {
+			s.Errors++
+		} else if ok {
+			s.Sets++
+		}
+	})
+	return ok, err
+}
+
+func (c *Instrumented) Has(ctx context.Context, key string) (bool, error) {
+	start := time.Now()
+	ok, err := c.next.Has(ctx, key)
+
+	c.record(c.family(key), time.Since(start), func(s *FamilyStats) {
+		if err != nil {
+			s.Errors++
+		}
+	})
+	return ok, err
+}
+
+func (c *Instrumented) Keys(ctx context.Context, pattern string) ([]string, error) {
+	start := time.Now()
+	ret, err := c.next.Keys(ctx, pattern)
+
+	c.record(c.family(pattern), time.Since(start), func(s *FamilyStats) {
+		if err != nil {
+			s.Errors++
+		}
+	})
+	return ret, err
+}
+
+func (c *Instrumented) Delete(ctx context.Context, keys ...string) error {
+	start := time.Now()
+	err := c.next.Delete(ctx, keys...)
+
+	family := FamilyOther
+	if len(keys) > 0 {
+		family = c.family(keys[0])
+	}
+	c.record(family, time.Since(start), func(s *FamilyStats) {
+		if err != nil {
+			s.Errors++
+		}
+	})
+	return err
+}
+
+// Unwrap returns the decorated cache.
+func (c *Instrumented) Unwrap() Cache {
+	return c.next
+}
+
+// Stats returns a copy of the usage of each key family seen so far, keyed by
+// the family's name.
+func (c *Instrumented) Stats() map[string]FamilyStats {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	ret := map[string]FamilyStats{}
+	for family, stats := range c.stats {
+		ret[family] = *stats
+	}
+	return ret
+}
+
+func (c *Instrumented) family(key string) string {
+	for _, family := range c.families {
+		if key == family.pattern || matchPattern(family.pattern, key) {
+			return family.name
+		}
+	}
+	return FamilyOther
+}
+
+func (c *Instrumented) record(family string, latency time.Duration, fn func(*FamilyStats)) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	stats, ok := c.stats[family]
+	if !ok {
+		stats = &FamilyStats{}
+		c.stats[family] = stats
+	}
+
+	stats.Operations++
+	stats.Latency += latency
+	fn(stats)
+}
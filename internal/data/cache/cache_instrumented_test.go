@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInstrumented(t *testing.T) {
+	original := Namespace
+	t.Cleanup(func() { Namespace = original })
+	Namespace = "prod"
+
+	schema := KeyFamily{Format: "game:%d:schema", Version: 2}
+	achievements := KeyFamily{Format: "player:%s:game:%d:achievements", Version: 1}
+	c := NewInstrumented(NewMemory(), []KeyFamily{schema, achievements})
+	ctx := context.Background()
+
+	if err := c.Set(ctx, schema.Key(620), "schema", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	val := ""
+	if err := c.Get(ctx, schema.Key(620), &val); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, schema.Key(570), &val); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if _, err := c.Keys(ctx, achievements.Pattern()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, "prod:v1:unknown", &val); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+
+	want := map[string]FamilyStats{
+		"game:*:schema":                {Hits: 1, Misses: 1, Sets: 1, Operations: 3},
+		"player:*:game:*:achievements": {Operations: 1},
+		FamilyOther:                    {Misses: 1, Operations: 1},
+	}
+
+	got := c.Stats()
+	if len(got) != len(want) {
+		t.Errorf("got stats for %d families, want %d", len(got), len(want))
+	}
+	for family, want := range want {
+		stats := got[family]
+		stats.Latency = 0
+		if stats != want {
+			t.Errorf("got %+v for %q, want %+v", stats, family, want)
+		}
+	}
+}
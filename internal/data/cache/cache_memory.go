@@ -2,93 +2,216 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 )
 
 type Memory struct {
-	data map[string][]byte
-	mx   sync.Mutex
+	data     map[string]memoryEntry
+	encoding Encoding
+	mx       sync.RWMutex
+	// swept is when expired entries were last removed.
+	swept time.Time
+
+	queues map[string][]string
+	// pushed is closed and replaced whenever a message is pushed, waking any
+	// waiting Pop calls.
+	pushed chan struct{}
 }
 
 var _ Cache = &Memory{}
+var _ Queue = &Memory{}
+
+// memoryEntry is an encoded value, alongside when it expires.
+type memoryEntry struct {
+	data []byte
+	// expiresAt is the zero time for entries that never expire.
+	expiresAt time.Time
+}
+
+// expired reports whether the entry has outlived its TTL.
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// memorySweepInterval is how often expired entries are removed, as they are
+// otherwise only ignored.
+const memorySweepInterval = time.Minute
 
 func NewMemory() *Memory {
 	return &Memory{
-		data: map[string][]byte{},
-		mx:   sync.Mutex{},
+		data:     map[string]memoryEntry{},
+		encoding: DefaultEncoding,
+		mx:       sync.RWMutex{},
+		queues:   map[string][]string{},
+		pushed:   make(chan struct{}),
 	}
 }
 
 func (c *Memory) Get(_ context.Context, key string, val any) error {
-	d, ok := c.data[key]
-	if !ok {
-		return fmt.Errorf("key not found")
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	entry, ok := c.data[key]
+	if !ok || entry.expired(time.Now()) {
+		return ErrNotFound
 	}
 
-	return json.Unmarshal(d, val)
+	return c.encoding.Decode(entry.data, val)
 }
 
-// Set will create a cache file for the given key.
-// TODO: Support TTL based expirations
+// SetEncoding changes how values are written to the cache. Existing entries
+// remain readable.
+func (c *Memory) SetEncoding(encoding Encoding) {
+	c.encoding = encoding
+}
+
+// Set stores the value under the given key, expiring it after the TTL. A TTL
+// of 0 keeps the value until it is deleted.
 func (c *Memory) Set(ctx context.Context, key string, val any, ttl time.Duration) error {
 	c.mx.Lock()
 	defer c.mx.Unlock()
 
-	var err error
-	c.data[key], err = json.Marshal(val)
-
-	if err == nil {
-		// Enforce the TTL by deleting the key after the specified duration
-		// Note that this is an unsafe operation. If the key is set again within the timeframe
-		// it will NOT extend the duration.
-		go func() {
-			select {
-			case <-ctx.Done():
-				return
-			case <-time.After(ttl):
-				c.mx.Lock()
-				defer c.mx.Unlock()
+	return c.set(ctx, key, val, ttl)
+}
+
+func (c *Memory) SetNX(ctx context.Context, key string, val any, ttl time.Duration) (bool, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if entry, ok := c.data[key]; ok && !entry.expired(time.Now()) {
+		return false, nil
+	}
+
+	return true, c.set(ctx, key, val, ttl)
+}
+
+// set stores the value. The caller must hold the write lock.
+func (c *Memory) set(_ context.Context, key string, val any, ttl time.Duration) error {
+	data, err := c.encoding.Encode(val)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	entry := memoryEntry{data: data}
+	if ttl > 0 {
+		entry.expiresAt = now.Add(ttl)
+	}
+	c.data[key] = entry
+
+	if now.Sub(c.swept) >= memorySweepInterval {
+		c.swept = now
+		for key, entry := range c.data {
+			if entry.expired(now) {
 				delete(c.data, key)
 			}
-		}()
+		}
 	}
 
-	return err
+	return nil
 }
 
 func (c *Memory) Has(ctx context.Context, key string) (bool, error) {
-	_, ok := c.data[key]
-	return ok, nil
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	entry, ok := c.data[key]
+	return ok && !entry.expired(time.Now()), nil
+}
+
+func (c *Memory) Delete(ctx context.Context, keys ...string) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	for _, key := range keys {
+		delete(c.data, key)
+	}
+
+	return nil
 }
 
 // Keys will return the set of keys in the cache matching the given "*" based pattern.
-// WARNING: This implementation only supports a single wildcard.
 func (c *Memory) Keys(ctx context.Context, pattern string) ([]string, error) {
-	patterns := strings.Split(pattern, "*")
-	if len(patterns) == 1 {
-		// Special case: no wildcards exist
-		if _, found := c.data[pattern]; found {
-			return []string{pattern}, nil
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	now := time.Now()
+	ret := []string{}
+	for key, entry := range c.data {
+		if !entry.expired(now) && matchPattern(pattern, key) {
+			ret = append(ret, key)
 		}
-	} else if len(patterns) > 2 {
-		return []string{}, fmt.Errorf("the memory cache does not support multiple wildcards")
 	}
 
-	ret := []string{}
-	for key := range c.data {
-		if !strings.HasPrefix(key, patterns[0]) {
-			continue
+	return ret, nil
+}
+
+// matchPattern reports whether key matches a pattern where "*" matches any
+// run of characters, following the Redis KEYS command.
+func matchPattern(pattern, key string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		// Special case: no wildcards exist
+		return pattern == key
+	}
+
+	if !strings.HasPrefix(key, parts[0]) {
+		return false
+	}
+	key = key[len(parts[0]):]
+
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		ix := strings.Index(key, part)
+		if ix < 0 {
+			return false
 		}
-		if !strings.HasSuffix(key, patterns[1]) {
-			continue
+		key = key[ix+len(part):]
+	}
+
+	return len(key) >= len(last) && strings.HasSuffix(key, last)
+}
+
+func (c *Memory) Push(_ context.Context, queue string, message string) error {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.queues[queue] = append(c.queues[queue], message)
+	close(c.pushed)
+	c.pushed = make(chan struct{})
+	return nil
+}
+
+func (c *Memory) Pop(ctx context.Context, queue string, timeout time.Duration) (string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		c.mx.Lock()
+		if messages := c.queues[queue]; len(messages) > 0 {
+			c.queues[queue] = messages[1:]
+			c.mx.Unlock()
+			return messages[0], nil
 		}
+		pushed := c.pushed
+		c.mx.Unlock()
 
-		ret = append(ret, key)
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-timer.C:
+			return "", ErrNotFound
+		case <-pushed:
+		}
 	}
+}
 
-	return ret, nil
+func (c *Memory) Pending(_ context.Context, queue string) ([]string, error) {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+
+	return slices.Clone(c.queues[queue]), nil
 }
@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{pattern: "game:620", key: "game:620", want: true},
+		{pattern: "game:620", key: "game:6200", want: false},
+		{pattern: "*", key: "", want: true},
+		{pattern: "*", key: "anything", want: true},
+		{pattern: "game:*", key: "game:620:schema", want: true},
+		{pattern: "game:*", key: "player:1", want: false},
+		{pattern: "*:schema", key: "game:620:schema", want: true},
+		{pattern: "*:schema", key: "game:620:global", want: false},
+		{pattern: "game:*:schema", key: "game:620:schema", want: true},
+		{pattern: "game:*:schema", key: "game:schema", want: false},
+		{pattern: "a*b*c", key: "abc", want: true},
+		{pattern: "a*b*c", key: "aXbYc", want: true},
+		{pattern: "a*b*c", key: "acb", want: false},
+		// The suffix may not overlap with the prefix
+		{pattern: "ab*ba", key: "aba", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryTTL(t *testing.T) {
+	c := NewMemory()
+	ctx := context.Background()
+	const ttl = 100 * time.Millisecond
+
+	if err := c.Set(ctx, "renewed", "value", ttl); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "expiring", "value", ttl); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set(ctx, "kept", "value", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Setting a key again extends its TTL
+	time.Sleep(ttl * 6 / 10)
+	if err := c.Set(ctx, "renewed", "value", ttl); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(ttl * 6 / 10)
+
+	for key, want := range map[string]bool{"renewed": true, "expiring": false, "kept": true} {
+		if got, err := c.Has(ctx, key); err != nil {
+			t.Fatal(err)
+		} else if got != want {
+			t.Errorf("%s: got present %v, want %v", key, got, want)
+		}
+	}
+
+	keys, err := c.Keys(ctx, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	if want := []string{"kept", "renewed"}; !slices.Equal(keys, want) {
+		t.Errorf("got keys %v, want %v", keys, want)
+	}
+
+	// Expired keys may be set again with SetNX
+	if ok, err := c.SetNX(ctx, "expiring", "value", ttl); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Error("got SetNX refused for an expired key")
+	}
+	if ok, err := c.SetNX(ctx, "renewed", "value", ttl); err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Error("got SetNX accepted for a current key")
+	}
+}
+
+// TestMemoryTTLOutlivesContext guards against keys set under a request context
+// never expiring once the request is done.
+func TestMemoryTTLOutlivesContext(t *testing.T) {
+	c := NewMemory()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if err := c.Set(ctx, "key", "value", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	time.Sleep(60 * time.Millisecond)
+
+	if got, err := c.Has(context.Background(), "key"); err != nil {
+		t.Fatal(err)
+	} else if got {
+		t.Error("got the key present past its TTL")
+	}
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRedisUnreachable(t *testing.T) {
+	// Reserve a port that nothing is listening on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	start := time.Now()
+	c := NewRedis(RedisOptions{Addrs: []string{addr}, ConnectTimeout: time.Millisecond * 200})
+	t.Cleanup(func() { _ = c.Client().Close() })
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("startup took %s, want it bounded by the connect timeout", elapsed)
+	}
+	if c.Healthy() {
+		t.Fatal("got healthy, want unhealthy")
+	}
+
+	ctx := context.Background()
+	var val string
+	if err := c.Get(ctx, "key", &val); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Get: got %v, want ErrUnavailable", err)
+	}
+	if err := c.Set(ctx, "key", "value", time.Minute); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Set: got %v, want ErrUnavailable", err)
+	}
+	if _, err := c.Pop(ctx, "queue", time.Second); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Pop: got %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,324 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tiered fronts a shared cache such as Redis (L2) with a small, bounded
+// in-process cache (L1). Hot keys are served from process memory without a
+// network round trip, while L2 remains the source of truth.
+//
+// L1 entries are kept for a short time only. When the L2 cache supports it,
+// writes are also broadcast to other replicas so that they can drop their
+// copy of the key immediately.
+type Tiered struct {
+	l1      *lru
+	l2      Cache
+	l1TTL   time.Duration
+	channel string
+	id      string
+	stats   map[string]*tierCounters
+}
+
+var _ Cache = &Tiered{}
+
+// TieredOptions configures a Tiered cache.
+type TieredOptions struct {
+	// L1Size is the maximum number of entries held in memory.
+	L1Size int
+	// L1TTL caps how long an entry is served from memory before it is read
+	// from L2 again.
+	L1TTL time.Duration
+	// InvalidationChannel enables invalidation between replicas over the
+	// named channel, if the L2 cache is a Notifier. Leave empty to disable.
+	InvalidationChannel string
+}
+
+// TierStats counts lookups against a single tier of a Tiered cache.
+type TierStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+type tierCounters struct {
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+const (
+	TierL1 = "l1"
+	TierL2 = "l2"
+)
+
+func NewTiered(l2 Cache, opts TieredOptions) *Tiered {
+	return &Tiered{
+		l1:      newLRU(opts.L1Size),
+		l2:      l2,
+		l1TTL:   opts.L1TTL,
+		channel: opts.InvalidationChannel,
+		id:      uuid.NewString(),
+		stats: map[string]*tierCounters{
+			TierL1: {},
+			TierL2: {},
+		},
+	}
+}
+
+func (c *Tiered) Get(ctx context.Context, key string, val any) error {
+	if d, ok := c.l1.get(key); ok {
+		c.stats[TierL1].hits.Add(1)
+		return json.Unmarshal(d, val)
+	}
+	c.stats[TierL1].misses.Add(1)
+
+	if err := c.l2.Get(ctx, key, val); err != nil {
+		c.stats[TierL2].misses.Add(1)
+		return err
+	}
+	c.stats[TierL2].hits.Add(1)
+
+	// Promote the value into L1 for the next caller
+	if d, err := json.Marshal(val); err == nil {
+		c.l1.set(key, d, c.l1TTL)
+	}
+	return nil
+}
+
+func (c *Tiered) Set(ctx context.Context, key string, val any, ttl time.Duration) error {
+	if err := c.l2.Set(ctx, key, val, ttl); err != nil {
+		c.l1.delete(key)
+		return err
+	}
+
+	l1TTL := c.l1TTL
+	if ttl > 0 && ttl < l1TTL {
+		l1TTL = ttl
+	}
+	if d, err := json.Marshal(val); err == nil {
+		c.l1.set(key, d, l1TTL)
+	}
+
+	c.publish(ctx, key)
+	return nil
+}
+
+// SetNX is decided by L2 alone, as L1 may hold a key since removed from it.
+// The value is left out of L1 until it is next read.
+func (c *Tiered) SetNX(ctx context.Context, key string, val any, ttl time.Duration) (bool, error) {
+	ok, err := c.l2.SetNX(ctx, key, val, ttl)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	c.l1.delete(key)
+	c.publish(ctx, key)
+	return true, nil
+}
+
+func (c *Tiered) Has(ctx context.Context, key string) (bool, error) {
+	if _, ok := c.l1.get(key); ok {
+		return true, nil
+	}
+
+	return c.l2.Has(ctx, key)
+}
+
+func (c *Tiered) Delete(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		c.l1.delete(key)
+	}
+
+	if err := c.l2.Delete(ctx, keys...); err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		c.publish(ctx, key)
+	}
+	return nil
+}
+
+// Keys are always listed from L2, as L1 only ever holds a subset of them.
+func (c *Tiered) Keys(ctx context.Context, pattern string) ([]string, error) {
+	return c.l2.Keys(ctx, pattern)
+}
+
+// Unwrap returns the L2 cache.
+func (c *Tiered) Unwrap() Cache {
+	return c.l2
+}
+
+// Stats returns the hit and miss counts for each tier, keyed by TierL1 and
+// TierL2.
+func (c *Tiered) Stats() map[string]TierStats {
+	ret := map[string]TierStats{}
+	for tier, counters := range c.stats {
+		ret[tier] = TierStats{
+			Hits:   counters.hits.Load(),
+			Misses: counters.misses.Load(),
+		}
+	}
+	return ret
+}
+
+const (
+	// listenMinBackoff and listenMaxBackoff bound how long Listen waits
+	// between attempts to subscribe to invalidations.
+	listenMinBackoff = time.Second
+	listenMaxBackoff = time.Minute
+)
+
+// Listen drops L1 entries as other replicas write them, until the context is
+// cancelled. It returns immediately if invalidation is not enabled.
+//
+// Subscribing is retried with backoff for as long as it fails, such as while
+// L2 is unreachable. As invalidations may have been missed while unsubscribed,
+// L1 is cleared every time the subscription is made.
+func (c *Tiered) Listen(ctx context.Context) {
+	notifier, ok := c.l2.(Notifier)
+	if c.channel == "" || !ok {
+		return
+	}
+
+	backoff := listenMinBackoff
+	for {
+		messages, err := notifier.Subscribe(ctx, c.channel)
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("Unable to subscribe to cache invalidations. Retrying.", "channel", c.channel, "retry-in", backoff, "error", err)
+		} else if err == nil {
+			c.l1.clear()
+			slog.Info("Listening for cache invalidations", "channel", c.channel)
+			c.invalidate(messages)
+			backoff = listenMinBackoff
+			if ctx.Err() == nil {
+				slog.Warn("Cache invalidation subscription lost. Subscribing again.", "channel", c.channel)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, listenMaxBackoff)
+	}
+}
+
+// invalidate drops the L1 entries named by each message, until the channel is
+// closed.
+func (c *Tiered) invalidate(messages <-chan string) {
+	for msg := range messages {
+		// Messages are formatted as "<sender>|<key>"
+		sender, key, found := strings.Cut(msg, "|")
+		if !found || sender == c.id {
+			continue
+		}
+
+		c.l1.delete(key)
+	}
+}
+
+func (c *Tiered) publish(ctx context.Context, key string) {
+	notifier, ok := c.l2.(Notifier)
+	if c.channel == "" || !ok {
+		return
+	}
+
+	if err := notifier.Publish(ctx, c.channel, c.id+"|"+key); err != nil {
+		slog.Warn("Unable to publish cache invalidation", "key", key, "error", err)
+	}
+}
+
+// lru is a size-bounded map of encoded values, evicting the least recently
+// used entry once full. Values are held encoded rather than as the decoded
+// structs so that callers never share mutable state with each other.
+type lru struct {
+	size  int
+	items map[string]*list.Element
+	order *list.List
+	mx    sync.Mutex
+}
+
+type lruEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		items: map[string]*list.Element{},
+		order: list.New(),
+	}
+}
+
+func (l *lru) get(key string) ([]byte, bool) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := el.Value.(*lruEntry)
+	if time.Now().After(entry.expires) {
+		l.order.Remove(el)
+		delete(l.items, key)
+		return nil, false
+	}
+
+	l.order.MoveToFront(el)
+	return entry.data, true
+}
+
+func (l *lru) set(key string, data []byte, ttl time.Duration) {
+	if l.size <= 0 || ttl <= 0 {
+		return
+	}
+
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	entry := &lruEntry{key: key, data: data, expires: time.Now().Add(ttl)}
+	if el, ok := l.items[key]; ok {
+		el.Value = entry
+		l.order.MoveToFront(el)
+		return
+	}
+
+	l.items[key] = l.order.PushFront(entry)
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+func (l *lru) clear() {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	clear(l.items)
+	l.order.Init()
+}
+
+func (l *lru) delete(key string) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		l.order.Remove(el)
+		delete(l.items, key)
+	}
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNotifier is an in-memory L2 that broadcasts to its subscribers, failing
+// to subscribe while unavailable is set.
+type fakeNotifier struct {
+	*Memory
+	mx          sync.Mutex
+	unavailable int
+	subscribers []chan string
+	subscribed  chan struct{}
+}
+
+func (n *fakeNotifier) Publish(_ context.Context, _ string, message string) error {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	for _, sub := range n.subscribers {
+		sub <- message
+	}
+	return nil
+}
+
+func (n *fakeNotifier) Subscribe(ctx context.Context, _ string) (<-chan string, error) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if n.unavailable > 0 {
+		n.unavailable--
+		return nil, ErrUnavailable
+	}
+
+	sub := make(chan string, 10)
+	n.subscribers = append(n.subscribers, sub)
+	n.subscribed <- struct{}{}
+	return sub, nil
+}
+
+// disconnect closes every subscription, as if the connection was lost.
+func (n *fakeNotifier) disconnect() {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	for _, sub := range n.subscribers {
+		close(sub)
+	}
+	n.subscribers = nil
+}
+
+func TestTieredInvalidation(t *testing.T) {
+	l2 := &fakeNotifier{Memory: NewMemory(), unavailable: 1, subscribed: make(chan struct{}, 10)}
+	opts := TieredOptions{L1Size: 10, L1TTL: time.Hour, InvalidationChannel: "invalidations"}
+	local := NewTiered(l2, opts)
+	remote := NewTiered(l2, opts)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go local.Listen(ctx)
+
+	waitSubscribed := func() {
+		t.Helper()
+		select {
+		case <-l2.subscribed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the subscription")
+		}
+	}
+	waitFor := func(key string, want string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			got := ""
+			if err := local.Get(ctx, key, &got); err != nil && !errors.Is(err, ErrNotFound) {
+				t.Fatal(err)
+			} else if got == want {
+				return
+			} else if time.Now().After(deadline) {
+				t.Fatalf("%s: got %q, want %q", key, got, want)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Subscribing is retried while L2 is unavailable
+	waitSubscribed()
+
+	// Writes by other replicas drop the local copy
+	if err := local.Set(ctx, "key", "old", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Set(ctx, "key", "new", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("key", "new")
+
+	// Writes made while disconnected are missed, so L1 is cleared once
+	// subscribed again
+	l2.disconnect()
+	if err := l2.Memory.Set(ctx, "key", "missed", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	waitSubscribed()
+	waitFor("key", "missed")
+}
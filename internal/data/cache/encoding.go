@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec converts values to and from bytes.
+type Codec interface {
+	Marshal(any) ([]byte, error)
+	Unmarshal([]byte, any) error
+}
+
+// CodecID identifies the codec used for a stored entry.
+type CodecID byte
+
+const (
+	CodecJSON    CodecID = 1
+	CodecGob     CodecID = 2
+	CodecMsgPack CodecID = 3
+)
+
+// Compression identifies the compression applied to a stored entry.
+type Compression byte
+
+const (
+	CompressionNone Compression = 0
+	CompressionGzip Compression = 1
+	CompressionZstd Compression = 2
+)
+
+// encodingVersion is the first byte of every entry written with a header. It
+// can never begin a JSON document, which is how entries written before the
+// header was introduced are told apart.
+const encodingVersion byte = 0x01
+
+// headerSize is the length of the [version, codec, compression] header.
+const headerSize = 3
+
+var codecs = map[CodecID]Codec{
+	CodecJSON:    jsonCodec{},
+	CodecGob:     gobCodec{},
+	CodecMsgPack: msgpackCodec{},
+}
+
+// Encoding describes how values are written to a cache backend. Any Encoding
+// is able to read entries written by any other, as each entry records its own
+// codec and compression.
+type Encoding struct {
+	Codec       CodecID
+	Compression Compression
+	// CompressAbove is the payload size in bytes beyond which Compression is
+	// applied. Smaller payloads are stored uncompressed.
+	CompressAbove int
+}
+
+// DefaultEncoding writes plain JSON, without a header, so that entries remain
+// readable by deploys that predate the header. Other codecs and compression
+// are opted into once every replica can read them.
+var DefaultEncoding = Encoding{
+	Codec:         CodecJSON,
+	Compression:   CompressionNone,
+	CompressAbove: 1024,
+}
+
+// ParseCodec returns the CodecID for a name such as "json", "gob" or "msgpack".
+func ParseCodec(name string) (CodecID, error) {
+	switch name {
+	case "json":
+		return CodecJSON, nil
+	case "gob":
+		return CodecGob, nil
+	case "msgpack":
+		return CodecMsgPack, nil
+	}
+	return 0, fmt.Errorf("unknown codec %q", name)
+}
+
+func (c CodecID) String() string {
+	switch c {
+	case CodecJSON:
+		return "json"
+	case CodecGob:
+		return "gob"
+	case CodecMsgPack:
+		return "msgpack"
+	}
+	return fmt.Sprintf("codec %d", byte(c))
+}
+
+// ParseCompression returns the Compression for a name such as "none", "gzip"
+// or "zstd".
+func ParseCompression(name string) (Compression, error) {
+	switch name {
+	case "none":
+		return CompressionNone, nil
+	case "gzip":
+		return CompressionGzip, nil
+	case "zstd":
+		return CompressionZstd, nil
+	}
+	return 0, fmt.Errorf("unknown compression %q", name)
+}
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	}
+	return fmt.Sprintf("compression %d", byte(c))
+}
+
+func (e Encoding) Encode(val any) ([]byte, error) {
+	codec, ok := codecs[e.Codec]
+	if !ok {
+		return nil, fmt.Errorf("unknown codec %d", e.Codec)
+	}
+
+	payload, err := codec.Marshal(val)
+	if err != nil {
+		return nil, err
+	}
+
+	compression := CompressionNone
+	if e.Compression != CompressionNone && len(payload) > e.CompressAbove {
+		compression = e.Compression
+	}
+
+	// Uncompressed JSON is written as it was before the header was introduced
+	if e.Codec == CodecJSON && compression == CompressionNone {
+		return payload, nil
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, headerSize+len(payload)))
+	buf.Write([]byte{encodingVersion, byte(e.Codec), byte(compression)})
+
+	switch compression {
+	case CompressionNone:
+		buf.Write(payload)
+	case CompressionGzip:
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(payload); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case CompressionZstd:
+		buf.Write(zstdEncoder.EncodeAll(payload, nil))
+	default:
+		return nil, fmt.Errorf("unknown compression %d", compression)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (e Encoding) Decode(data []byte, val any) error {
+	if raw, ok := val.(*Raw); ok {
+		*raw = slices.Clone(data)
+		return nil
+	}
+
+	codecID, _, payload, err := decompress(data)
+	if err != nil {
+		return err
+	}
+
+	return codecs[codecID].Unmarshal(payload, val)
+}
+
+// Raw receives an entry as it is stored when passed to Cache.Get, rather than
+// decoding it, for it to be examined with Inspect.
+type Raw []byte
+
+// Entry describes a stored entry, for inspection.
+type Entry struct {
+	Codec       CodecID
+	Compression Compression
+	// Size is the length of the stored entry in bytes.
+	Size int
+	// Value is the decoded entry. Gob entries cannot be decoded without their
+	// type, and leave it nil.
+	Value any
+}
+
+// Inspect decodes a stored entry without knowing its type, reporting how it
+// was encoded.
+func Inspect(data []byte) (Entry, error) {
+	codecID, compression, payload, err := decompress(data)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	ret := Entry{Codec: codecID, Compression: compression, Size: len(data)}
+	if codecID == CodecGob {
+		return ret, nil
+	}
+
+	err = codecs[codecID].Unmarshal(payload, &ret.Value)
+	return ret, err
+}
+
+// decompress reads the header of a stored entry, returning its codec,
+// compression and uncompressed payload.
+func decompress(data []byte) (CodecID, Compression, []byte, error) {
+	// Entries without a header are plain JSON
+	if len(data) == 0 || data[0] != encodingVersion {
+		return CodecJSON, CompressionNone, data, nil
+	}
+
+	if len(data) < headerSize {
+		return 0, 0, nil, fmt.Errorf("truncated cache entry header")
+	}
+
+	codecID, compression := CodecID(data[1]), Compression(data[2])
+	if _, ok := codecs[codecID]; !ok {
+		return 0, 0, nil, fmt.Errorf("unknown codec %d", data[1])
+	}
+
+	payload := data[headerSize:]
+	switch compression {
+	case CompressionNone:
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		defer r.Close()
+
+		if payload, err = io.ReadAll(r); err != nil {
+			return 0, 0, nil, err
+		}
+	case CompressionZstd:
+		var err error
+		if payload, err = zstdDecoder.DecodeAll(payload, nil); err != nil {
+			return 0, 0, nil, err
+		}
+	default:
+		return 0, 0, nil, fmt.Errorf("unknown compression %d", data[2])
+	}
+
+	return codecID, compression, payload, nil
+}
+
+// The zstd encoder and decoder are safe for concurrent use through their
+// EncodeAll and DecodeAll methods, so are shared.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(val any) ([]byte, error)      { return json.Marshal(val) }
+func (jsonCodec) Unmarshal(data []byte, val any) error { return json.Unmarshal(data, val) }
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(val any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := gob.NewEncoder(buf).Encode(val)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, val any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(val)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(val any) ([]byte, error)      { return msgpack.Marshal(val) }
+func (msgpackCodec) Unmarshal(data []byte, val any) error { return msgpack.Unmarshal(data, val) }
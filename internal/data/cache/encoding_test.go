@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type encodingValue struct {
+	Name   string
+	Count  int
+	Labels []string
+}
+
+func TestEncoding(t *testing.T) {
+	small := encodingValue{Name: "small", Count: 1, Labels: []string{"a"}}
+	large := encodingValue{Name: strings.Repeat("large", 500), Count: 2, Labels: []string{"a", "b"}}
+
+	tests := []struct {
+		name     string
+		encoding Encoding
+		val      encodingValue
+		// plain is set when the entry should be written as headerless JSON.
+		plain bool
+	}{
+		{name: "default", encoding: DefaultEncoding, val: large, plain: true},
+		{name: "json zstd small", encoding: Encoding{Codec: CodecJSON, Compression: CompressionZstd, CompressAbove: 1024}, val: small, plain: true},
+		{name: "json zstd large", encoding: Encoding{Codec: CodecJSON, Compression: CompressionZstd, CompressAbove: 1024}, val: large},
+		{name: "json gzip large", encoding: Encoding{Codec: CodecJSON, Compression: CompressionGzip, CompressAbove: 1024}, val: large},
+		{name: "gob", encoding: Encoding{Codec: CodecGob}, val: small},
+		{name: "gob zstd", encoding: Encoding{Codec: CodecGob, Compression: CompressionZstd}, val: large},
+		{name: "msgpack", encoding: Encoding{Codec: CodecMsgPack}, val: small},
+		{name: "msgpack gzip", encoding: Encoding{Codec: CodecMsgPack, Compression: CompressionGzip}, val: large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := tt.encoding.Encode(tt.val)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if plain := json.Valid(data); plain != tt.plain {
+				t.Errorf("got plain JSON %v, want %v", plain, tt.plain)
+			}
+
+			// Any encoding is able to read entries written by any other
+			got := encodingValue{}
+			if err := (Encoding{Codec: CodecMsgPack}).Decode(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.val) {
+				t.Errorf("got %+v, want %+v", got, tt.val)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"truncated header":    {encodingVersion, byte(CodecJSON)},
+		"unknown codec":       {encodingVersion, 9, byte(CompressionNone), '{', '}'},
+		"unknown compression": {encodingVersion, byte(CodecJSON), 9, '{', '}'},
+		"corrupt zstd":        {encodingVersion, byte(CodecJSON), byte(CompressionZstd), 1, 2, 3},
+		"invalid json":        []byte("{"),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := encodingValue{}
+			if err := DefaultEncoding.Decode(data, &got); err == nil {
+				t.Errorf("got %+v, want an error", got)
+			}
+		})
+	}
+}
+
+func TestInspect(t *testing.T) {
+	val := encodingValue{Name: "inspected", Count: 3}
+
+	tests := []struct {
+		encoding Encoding
+		want     any
+	}{
+		{encoding: DefaultEncoding, want: map[string]any{"Name": "inspected", "Count": float64(3), "Labels": nil}},
+		{encoding: Encoding{Codec: CodecGob, Compression: CompressionGzip}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding.Codec.String(), func(t *testing.T) {
+			data, err := tt.encoding.Encode(val)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entry, err := Inspect(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.Codec != tt.encoding.Codec || entry.Size != len(data) {
+				t.Errorf("got %s entry of %d bytes, want %s of %d", entry.Codec, entry.Size, tt.encoding.Codec, len(data))
+			}
+			if !reflect.DeepEqual(entry.Value, tt.want) {
+				t.Errorf("got %#v, want %#v", entry.Value, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Freshness reports how current the cached data behind a result is.
+type Freshness struct {
+	// AsOf is when the oldest piece of data used was loaded.
+	AsOf time.Time
+	// Stale is set when any of that data was past its TTL and is being
+	// refreshed in the background.
+	Stale bool
+}
+
+type freshnessKey struct{}
+
+type freshnessTracker struct {
+	mx sync.Mutex
+	Freshness
+}
+
+// TrackFreshness returns a context that records the age of every entry
+// returned by GetOrLoad through it. Call the returned function once loading is
+// complete.
+func TrackFreshness(ctx context.Context) (context.Context, func() Freshness) {
+	tracker := &freshnessTracker{}
+	ctx = context.WithValue(ctx, freshnessKey{}, tracker)
+
+	return ctx, func() Freshness {
+		tracker.mx.Lock()
+		defer tracker.mx.Unlock()
+		return tracker.Freshness
+	}
+}
+
+func observeFreshness(ctx context.Context, fetchedAt time.Time, stale bool) {
+	tracker, ok := ctx.Value(freshnessKey{}).(*freshnessTracker)
+	if !ok {
+		return
+	}
+
+	tracker.mx.Lock()
+	defer tracker.mx.Unlock()
+
+	if tracker.AsOf.IsZero() || fetchedAt.Before(tracker.AsOf) {
+		tracker.AsOf = fetchedAt
+	}
+	tracker.Stale = tracker.Stale || stale
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Namespace is prefixed to every key built from a KeyFamily, allowing multiple
+// environments to share a single Redis database. It should be set once at
+// startup, before any keys are built.
+var Namespace = ""
+
+// KeyFamily describes a set of cache keys sharing a format and payload type,
+// such as the schemas of every game.
+//
+// Keys are built as "<namespace>:v<version>:<format>". Bumping the Version
+// whenever the payload type changes shape means that entries written by older
+// deploys are never decoded into the new type; they are simply ignored until
+// they expire.
+type KeyFamily struct {
+	// Format is a fmt format string for the key, such as "game:%d:schema".
+	// Only the %d and %s verbs are supported.
+	Format string
+	// Version is the current version of the payload type.
+	Version int
+}
+
+var verbPattern = regexp.MustCompile(`%[ds]`)
+
+// Key builds the key for the given format arguments.
+func (f KeyFamily) Key(args ...any) string {
+	return f.prefix() + fmt.Sprintf(f.Format, args...)
+}
+
+// Pattern returns a "*" based pattern matching every key in the family, for use
+// with Cache.Keys.
+func (f KeyFamily) Pattern() string {
+	return f.prefix() + verbPattern.ReplaceAllString(f.Format, "*")
+}
+
+// Name identifies the family independently of its namespace and version, such
+// as "game:*:schema".
+func (f KeyFamily) Name() string {
+	return verbPattern.ReplaceAllString(f.Format, "*")
+}
+
+// Parse extracts the format arguments from a key belonging to the family. Each
+// argument is returned as the string it was formatted as.
+func (f KeyFamily) Parse(key string) ([]string, error) {
+	rest, ok := strings.CutPrefix(key, f.prefix())
+	if !ok {
+		return nil, fmt.Errorf("key %q does not belong to family %q", key, f.Format)
+	}
+
+	match := f.parser().FindStringSubmatch(rest)
+	if match == nil {
+		return nil, fmt.Errorf("key %q does not match family %q", key, f.Format)
+	}
+
+	return match[1:], nil
+}
+
+// parsers holds the compiled expression for each family's format.
+var parsers sync.Map
+
+func (f KeyFamily) parser() *regexp.Regexp {
+	if r, ok := parsers.Load(f.Format); ok {
+		return r.(*regexp.Regexp)
+	}
+
+	parts := verbPattern.Split(f.Format, -1)
+	expr := "^"
+	for i, part := range parts {
+		expr += regexp.QuoteMeta(part)
+		if i < len(parts)-1 {
+			expr += "(.+?)"
+		}
+	}
+
+	r, _ := parsers.LoadOrStore(f.Format, regexp.MustCompile(expr+"$"))
+	return r.(*regexp.Regexp)
+}
+
+func (f KeyFamily) prefix() string {
+	ret := fmt.Sprintf("v%d:", f.Version)
+	if Namespace != "" {
+		ret = Namespace + ":" + ret
+	}
+	return ret
+}
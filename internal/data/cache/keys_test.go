@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKeyFamily(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		family    KeyFamily
+		key       string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "single argument", family: KeyFamily{Format: "game:%d:schema", Version: 2}, key: "v2:game:620:schema", want: []string{"620"}},
+		{name: "many arguments", family: KeyFamily{Format: "player:%s:game:%d:achievements", Version: 1}, key: "v1:player:76561:game:620:achievements", want: []string{"76561", "620"}},
+		{name: "no arguments", family: KeyFamily{Format: "jobs:queue", Version: 1}, key: "v1:jobs:queue", want: []string{}},
+		{name: "namespaced", namespace: "prod", family: KeyFamily{Format: "game:%d:schema", Version: 2}, key: "prod:v2:game:620:schema", want: []string{"620"}},
+		{name: "other namespace", namespace: "prod", family: KeyFamily{Format: "game:%d:schema", Version: 2}, key: "staging:v2:game:620:schema", wantErr: true},
+		{name: "old version", family: KeyFamily{Format: "game:%d:schema", Version: 2}, key: "v1:game:620:schema", wantErr: true},
+		{name: "other family", family: KeyFamily{Format: "game:%d:schema", Version: 2}, key: "v2:game:620:global", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := Namespace
+			t.Cleanup(func() { Namespace = original })
+			Namespace = tt.namespace
+
+			got, err := tt.family.Parse(tt.key)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want an error", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+
+			// Keys of the family are matched by its pattern
+			if !matchPattern(tt.family.Pattern(), tt.key) {
+				t.Errorf("pattern %q does not match %q", tt.family.Pattern(), tt.key)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Loader fetches the value for a cache key from its source of truth.
+type Loader[T any] func(context.Context) (T, error)
+
+// LoadOption customizes the behavior of GetOrLoad.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	staleFor  time.Duration
+	negatives map[error]time.Duration
+}
+
+// StaleFor keeps entries for an additional duration past their TTL. During
+// that time the stale value is returned immediately while it is refreshed in
+// the background, and continues to be served if the refresh fails.
+func StaleFor(d time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.staleFor = d
+	}
+}
+
+// CacheErrors remembers loader errors matching (per errors.Is) any of the given
+// errors for the paired duration, returning them again without calling the
+// loader. Any other error is considered transient and is never cached.
+func CacheErrors(ttls map[error]time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.negatives = ttls
+	}
+}
+
+// Incomplete is implemented by loaded values that may be missing parts, such as
+// when a source they are built from was unavailable. Incomplete values are
+// cached as already stale, so that they are loaded again the next time they
+// are read rather than served for their whole TTL.
+type Incomplete interface {
+	Incomplete() bool
+}
+
+// refreshTimeout bounds background refreshes, which are no longer tied to any
+// request context.
+const refreshTimeout = time.Minute
+
+// loads coalesces concurrent loads of the same key into a single call.
+var loads singleflight.Group
+
+// entry wraps a loaded value with the time it was retrieved.
+type entry[T any] struct {
+	Value T
+	// Error records a cached loader error in place of a Value, by its message.
+	Error     string `json:",omitempty"`
+	FetchedAt time.Time
+	StaleAt   time.Time
+}
+
+// GetOrLoad returns the value cached under key, calling loader to populate it
+// on a miss. The value is cached for ttl.
+//
+// Concurrent misses for the same key share a single call to loader, with each
+// caller receiving the same result or error. Errors reading from or writing to
+// the cache are logged and otherwise treated as a miss, so that an unavailable
+// cache does not take the loader down with it.
+func GetOrLoad[T any](ctx context.Context, c Cache, key string, ttl time.Duration, loader Loader[T], opts ...LoadOption) (T, error) {
+	o := loadOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Check the cache to see if we've already loaded
+	cached := entry[T]{}
+	if err := c.Get(ctx, key, &cached); err == nil && !cached.FetchedAt.IsZero() {
+		if cached.Error != "" {
+			var zero T
+			return zero, o.cachedError(cached.Error)
+		}
+
+		stale := time.Now().After(cached.StaleAt)
+		if stale {
+			refresh(ctx, c, key, ttl, loader, o)
+		}
+
+		observeFreshness(ctx, cached.FetchedAt, stale)
+		return cached.Value, nil
+	}
+
+	// Nope! Load it, detached from the caller's cancellation so that one
+	// client disconnecting does not fail every other caller waiting on it
+	ch := loads.DoChan(key, func() (any, error) {
+		return store(context.WithoutCancel(ctx), c, key, ttl, loader, o)
+	})
+
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case res := <-ch:
+		ret, err := result[T](key, res.Val, res.Err)
+		if err == nil {
+			observeFreshness(ctx, time.Now(), false)
+		}
+		return ret, err
+	}
+}
+
+// Refresh calls loader and caches its result under key, regardless of whether
+// a current entry already exists. It is coalesced with any in-flight loads of
+// the same key.
+func Refresh[T any](ctx context.Context, c Cache, key string, ttl time.Duration, loader Loader[T], opts ...LoadOption) (T, error) {
+	o := loadOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	res, err, _ := loads.Do(key, func() (any, error) {
+		return store(ctx, c, key, ttl, loader, o)
+	})
+	return result[T](key, res, err)
+}
+
+// result asserts the value of a coalesced load to T. Loads are coalesced by
+// key alone, so a caller joining a load of the same key as another type would
+// otherwise receive a zero value without any error.
+func result[T any](key string, val any, err error) (T, error) {
+	ret, ok := val.(T)
+	if !ok && val != nil {
+		return ret, fmt.Errorf("cache key %q was loaded as %T rather than %T", key, val, ret)
+	}
+	return ret, err
+}
+
+// refresh reloads a stale entry without blocking the caller. Failures are
+// logged and the stale entry is left in place until it expires.
+func refresh[T any](ctx context.Context, c Cache, key string, ttl time.Duration, loader Loader[T], o loadOptions) {
+	// The result channel is buffered, so nothing needs to wait on it
+	loads.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+		defer cancel()
+
+		ret, err := store(ctx, c, key, ttl, loader, o)
+		if err != nil {
+			slog.Warn("Unable to refresh stale cache entry", "key", key, "error", err)
+		}
+
+		return ret, err
+	})
+}
+
+// store calls loader and writes its result to the cache, along with any error
+// that is configured to be cached.
+func store[T any](ctx context.Context, c Cache, key string, ttl time.Duration, loader Loader[T], o loadOptions) (T, error) {
+	ret, err := loader(ctx)
+	now := time.Now()
+	if err != nil {
+		for target, negativeTTL := range o.negatives {
+			if !errors.Is(err, target) {
+				continue
+			}
+
+			cached := entry[T]{Error: target.Error(), FetchedAt: now, StaleAt: now}
+			if setErr := c.Set(ctx, key, cached, negativeTTL); setErr != nil && !errors.Is(setErr, ErrUnavailable) {
+				slog.Warn("Unable to cache error", "key", key, "error", setErr)
+			}
+			break
+		}
+
+		return ret, err
+	}
+
+	cached := entry[T]{
+		Value:     ret,
+		FetchedAt: now,
+		StaleAt:   now.Add(ttl),
+	}
+	if v, ok := any(ret).(Incomplete); ok && v.Incomplete() {
+		cached.StaleAt = now
+	}
+	if setErr := c.Set(ctx, key, cached, ttl+o.staleFor); setErr != nil && !errors.Is(setErr, ErrUnavailable) {
+		slog.Warn("Unable to cache loaded value", "key", key, "error", setErr)
+	}
+
+	return ret, nil
+}
+
+// cachedError rebuilds a cached loader error from its message.
+func (o loadOptions) cachedError(msg string) error {
+	for target := range o.negatives {
+		if target.Error() == msg {
+			return fmt.Errorf("cached: %w", target)
+		}
+	}
+
+	return fmt.Errorf("cached: %s", msg)
+}
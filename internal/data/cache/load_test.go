@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoad(t *testing.T) {
+	errMissing := errors.New("missing")
+	errTransient := errors.New("transient")
+
+	tests := []struct {
+		name string
+		// seed is cached under the key before loading, when set.
+		seed    *entry[string]
+		results []error
+		opts    []LoadOption
+		want    []string
+		wantErr []error
+		// wantCalls is the number of times the loader is expected to be called.
+		wantCalls int
+	}{
+		{
+			name:      "miss then hit",
+			results:   []error{nil, nil},
+			want:      []string{"loaded-1", "loaded-1"},
+			wantErr:   []error{nil, nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "fresh entry",
+			seed:      &entry[string]{Value: "cached", FetchedAt: time.Now(), StaleAt: time.Now().Add(time.Hour)},
+			results:   []error{nil},
+			want:      []string{"cached"},
+			wantErr:   []error{nil},
+			wantCalls: 0,
+		},
+		{
+			name:      "stale entry",
+			seed:      &entry[string]{Value: "cached", FetchedAt: time.Now().Add(-time.Hour), StaleAt: time.Now().Add(-time.Minute)},
+			results:   []error{nil},
+			want:      []string{"cached"},
+			wantErr:   []error{nil},
+			wantCalls: 1,
+		},
+		{
+			name:      "cached error",
+			results:   []error{errMissing, errMissing},
+			opts:      []LoadOption{CacheErrors(map[error]time.Duration{errMissing: time.Minute})},
+			want:      []string{"", ""},
+			wantErr:   []error{errMissing, errMissing},
+			wantCalls: 1,
+		},
+		{
+			name:      "transient error",
+			results:   []error{errTransient, nil},
+			opts:      []LoadOption{CacheErrors(map[error]time.Duration{errMissing: time.Minute})},
+			want:      []string{"", "loaded-2"},
+			wantErr:   []error{errTransient, nil},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewMemory()
+			key := "load:" + t.Name()
+			if tt.seed != nil {
+				if err := c.Set(context.Background(), key, *tt.seed, time.Hour); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var calls atomic.Int32
+			loaded := make(chan struct{}, len(tt.results))
+			loader := func(context.Context) (string, error) {
+				call := int(calls.Add(1))
+				defer func() { loaded <- struct{}{} }()
+				if err := tt.results[call-1]; err != nil {
+					return "", err
+				}
+				return "loaded-" + string(rune('0'+call)), nil
+			}
+
+			for i := range tt.results {
+				got, err := GetOrLoad(context.Background(), c, key, time.Hour, loader, tt.opts...)
+				if !errors.Is(err, tt.wantErr[i]) {
+					t.Errorf("load %d: got error %v, want %v", i, err, tt.wantErr[i])
+				}
+				if got != tt.want[i] {
+					t.Errorf("load %d: got %q, want %q", i, got, tt.want[i])
+				}
+			}
+
+			// Stale entries are refreshed in the background
+			for range tt.wantCalls {
+				select {
+				case <-loaded:
+				case <-time.After(time.Second):
+					t.Fatal("timed out waiting for the loader")
+				}
+			}
+			if got := int(calls.Load()); got != tt.wantCalls {
+				t.Errorf("got %d loader calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestGetOrLoadCoalesces(t *testing.T) {
+	c := NewMemory()
+	key := "load:" + t.Name()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(context.Context) (string, error) {
+		calls.Add(1)
+		<-release
+		return "loaded", nil
+	}
+
+	const callers = 10
+	wg := sync.WaitGroup{}
+	results := make(chan string, callers)
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := GetOrLoad(context.Background(), c, key, time.Hour, loader)
+			if err != nil {
+				t.Error(err)
+			}
+			results <- got
+		}()
+	}
+
+	// Give every caller the chance to join the in-flight load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for got := range results {
+		if got != "loaded" {
+			t.Errorf("got %q, want %q", got, "loaded")
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("got %d loader calls, want 1", got)
+	}
+}
+
+func TestGetOrLoadMismatchedType(t *testing.T) {
+	c := NewMemory()
+	key := "load:" + t.Name()
+
+	release := make(chan struct{})
+	loaded := make(chan struct{})
+	go func() {
+		defer close(loaded)
+		_, _ = GetOrLoad(context.Background(), c, key, time.Hour, func(context.Context) (string, error) {
+			<-release
+			return "loaded", nil
+		})
+	}()
+
+	// Join the in-flight load of a string as an int
+	time.Sleep(50 * time.Millisecond)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+
+	got, err := GetOrLoad(context.Background(), c, key, time.Hour, func(context.Context) (int, error) {
+		return 1, nil
+	})
+	<-loaded
+	if err == nil {
+		t.Errorf("got %d, want an error", got)
+	}
+}
+
+type incompleteValue struct {
+	Missing bool
+}
+
+func (v incompleteValue) Incomplete() bool {
+	return v.Missing
+}
+
+func TestGetOrLoadIncomplete(t *testing.T) {
+	c := NewMemory()
+	key := "load:" + t.Name()
+
+	var calls atomic.Int32
+	loaded := make(chan struct{}, 2)
+	loader := func(context.Context) (incompleteValue, error) {
+		defer func() { loaded <- struct{}{} }()
+		return incompleteValue{Missing: calls.Add(1) == 1}, nil
+	}
+
+	if _, err := GetOrLoad(context.Background(), c, key, time.Hour, loader); err != nil {
+		t.Fatal(err)
+	}
+
+	// The incomplete value is served while it is loaded again
+	got, err := GetOrLoad(context.Background(), c, key, time.Hour, loader)
+	if err != nil {
+		t.Fatal(err)
+	} else if !got.Missing {
+		t.Error("got the complete value, want the incomplete one while loading")
+	}
+
+	for range 2 {
+		select {
+		case <-loaded:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the loader")
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("got %d loader calls, want 2", got)
+	}
+}
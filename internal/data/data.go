@@ -5,17 +5,27 @@ package data
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/taiidani/achievements/internal/data/cache"
+	"github.com/taiidani/achievements/internal/data/history"
 	"github.com/taiidani/achievements/internal/steam"
+	"golang.org/x/sync/errgroup"
 )
 
 type Data struct {
-	cache cache.Cache
-	steam SteamHelper
+	cache   cache.Cache
+	steam   *SteamHelper
+	history history.Store
+	scorer  Scorer
+	jobs    *Jobs
+	events  *Bus
+	// active records when each user was last marked as active
+	active sync.Map
 }
 
 type Game struct {
@@ -25,6 +35,7 @@ type Game struct {
 	PlaytimeForever time.Duration
 	LastPlayed      time.Time
 	LastPlayedSince time.Duration
+	cache.Freshness
 }
 
 type Achievements struct {
@@ -32,14 +43,25 @@ type Achievements struct {
 	AchievementTotalCount         int
 	AchievementUnlockedCount      int
 	AchievementUnlockedPercentage int
+	// Points is the score of the unlocked achievements, out of the
+	// PossiblePoints for every achievement in the game.
+	Points         int
+	PossiblePoints int
+	// Estimate projects the playtime remaining to unlock every achievement,
+	// when there is enough progress to extrapolate from.
+	Estimate *CompletionEstimate
+	cache.Freshness
 }
 
 type Achievement struct {
+	// APIName identifies the achievement within its game's schema.
+	APIName          string
 	Name             string
 	Description      string
 	Hidden           bool
 	Icon             string
 	GlobalPercentage float64
+	Points           int
 	Achieved         bool
 	UnlockedOn       *time.Time
 }
@@ -53,11 +75,22 @@ type User struct {
 	TimeCreated time.Time
 }
 
-func NewData(client *steam.Client, cache cache.Cache) *Data {
-	return &Data{
-		cache: cache,
-		steam: *NewSteamHelper(client, cache),
+// NewData builds the data layer. The history store is optional, and unlock
+// history is not recorded when it is nil.
+func NewData(client *steam.Client, cache cache.Cache, history history.Store) *Data {
+	d := &Data{
+		cache:   cache,
+		steam:   NewSteamHelper(client, cache),
+		history: history,
+		scorer:  scorers[DefaultScorer],
+		events:  NewBus(),
 	}
+	// History only needs recording when there is something new to compare
+	d.steam.onPlayerAchievements = func(ctx context.Context, userID string, appID uint64, achievements *steam.PlayerAchievements) {
+		d.recordHistory(ctx, userID, appID, achievements)
+	}
+	d.subscribeWebhooks()
+	return d
 }
 
 func (d *Data) GetUser(ctx context.Context, userID string) (User, error) {
@@ -85,6 +118,10 @@ func (d *Data) GetUser(ctx context.Context, userID string) (User, error) {
 		newData.LastLogoff = time.Unix(int64(user.LastLogoff), 0)
 	}
 
+	// Every page showing a user looks them up, making this the point at which
+	// they are considered active
+	d.markActive(ctx, userID)
+
 	return newData, nil
 }
 
@@ -92,12 +129,14 @@ func (d *Data) GetGames(ctx context.Context, userID string) ([]Game, error) {
 	log := slog.With("steam-id", userID)
 
 	log.Debug("Retrieving user owned games")
+	ctx, freshness := cache.TrackFreshness(ctx)
 	steamGames, err := d.steam.GetPlayerOwnedGames(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("could not query for player %q games: %w", userID, err)
 	}
 
 	ret := []Game{}
+	fresh := freshness()
 	for _, game := range steamGames.Response.Games {
 		if game.PlaytimeForever == 0 {
 			continue
@@ -107,6 +146,7 @@ func (d *Data) GetGames(ctx context.Context, userID string) ([]Game, error) {
 			ID:          game.AppID,
 			DisplayName: game.Name,
 			Icon:        game.ImgIconURL,
+			Freshness:   fresh,
 		}
 
 		if err := d.populateGamePlaytime(&newData, &game); err != nil {
@@ -123,6 +163,7 @@ func (d *Data) GetGame(ctx context.Context, userID string, appID uint64) (Game,
 	log := slog.With("steam-id", userID, "app-id", appID)
 
 	log.Debug("Retrieving user owned games")
+	ctx, freshness := cache.TrackFreshness(ctx)
 	steamGames, err := d.steam.GetPlayerOwnedGames(ctx, userID)
 	if err != nil {
 		return Game{}, fmt.Errorf("could not query for player %q games: %w", userID, err)
@@ -141,6 +182,7 @@ func (d *Data) GetGame(ctx context.Context, userID string, appID uint64) (Game,
 		ID:          steamGame.AppID,
 		Icon:        steamGame.ImgIconURL,
 		DisplayName: steamGame.Name,
+		Freshness:   freshness(),
 	}
 
 	if err := d.populateGamePlaytime(&newData, &steamGame); err != nil {
@@ -184,6 +226,7 @@ func (d *Data) HasAchievements(ctx context.Context, gameID uint64) (bool, error)
 func (d *Data) GetAchievements(ctx context.Context, userID string, gameID uint64) (Achievements, error) {
 	log := slog.With("game-id", gameID)
 	log.Debug("Retrieving schema for game")
+	ctx, freshness := cache.TrackFreshness(ctx)
 	schema, err := d.steam.GetSchemaForGame(ctx, gameID)
 	if err != nil {
 		return Achievements{}, fmt.Errorf("unable to retrieve game schema: %w", err)
@@ -202,7 +245,11 @@ func (d *Data) GetAchievements(ctx context.Context, userID string, gameID uint64
 	log.Debug("Retrieving player achievements for game")
 	playerAchievements, err := d.steam.GetPlayerAchievements(ctx, userID, gameID)
 	if err != nil {
-		log.Warn("Unable to get player achievements for game. Leaving empty.", "err", err)
+		if errors.Is(err, steam.ErrNoStats) || errors.Is(err, steam.ErrPrivateProfile) {
+			log.Debug("No player achievements available for game. Leaving empty.", "err", err)
+		} else {
+			log.Warn("Unable to get player achievements for game. Leaving empty.", "err", err)
+		}
 		playerAchievements = &steam.PlayerAchievements{
 			PlayerStats: steam.PlayerStats{
 				Achievements: []steam.PlayerAchievement{},
@@ -210,12 +257,24 @@ func (d *Data) GetAchievements(ctx context.Context, userID string, gameID uint64
 		}
 	}
 
-	ret := Achievements{}
+	ret := Achievements{Freshness: freshness()}
 	ret.AchievementTotalCount = len(playerAchievements.PlayerStats.Achievements)
 	ret.AchievementUnlockedCount = 0
 
+	// Index the global percentages and player unlocks by API name, to join
+	// them against the schema
+	globalPercentages := map[string]float64{}
+	for _, globalAchievement := range globalAchievements.AchievementPercentages.Achievements {
+		globalPercentages[globalAchievement.Name] = globalAchievement.Percent
+	}
+	playerUnlocks := map[string]steam.PlayerAchievement{}
+	for _, playerAchievement := range playerAchievements.PlayerStats.Achievements {
+		playerUnlocks[playerAchievement.APIName] = playerAchievement
+	}
+
 	for _, gameAchievement := range schema.Game.AvailableGameStats.Achievements {
 		bagAchievement := Achievement{
+			APIName:     gameAchievement.Name,
 			Name:        gameAchievement.DisplayName,
 			Description: gameAchievement.Description,
 			Hidden:      gameAchievement.Hidden > 0,
@@ -224,26 +283,23 @@ func (d *Data) GetAchievements(ctx context.Context, userID string, gameID uint64
 		}
 
 		// Get the global completion percentage
-		for _, globalAchievement := range globalAchievements.AchievementPercentages.Achievements {
-			if gameAchievement.Name == globalAchievement.Name {
-				bagAchievement.GlobalPercentage = globalAchievement.Percent
-			}
-		}
+		bagAchievement.GlobalPercentage = globalPercentages[gameAchievement.Name]
 
 		// And this player's unlock, if present
-		for _, playerAchievement := range playerAchievements.PlayerStats.Achievements {
-			if gameAchievement.Name == playerAchievement.APIName {
-				bagAchievement.Achieved = playerAchievement.Achieved > 0
-				bagAchievement.Hidden = !bagAchievement.Achieved && bagAchievement.Hidden
-
-				if playerAchievement.UnlockTime > 0 {
-					tm := time.Unix(int64(playerAchievement.UnlockTime), 0)
-					bagAchievement.UnlockedOn = &tm
-				}
+		if playerAchievement, ok := playerUnlocks[gameAchievement.Name]; ok {
+			bagAchievement.Achieved = playerAchievement.Achieved > 0
+			bagAchievement.Hidden = !bagAchievement.Achieved && bagAchievement.Hidden
+
+			if playerAchievement.UnlockTime > 0 {
+				tm := time.Unix(int64(playerAchievement.UnlockTime), 0)
+				bagAchievement.UnlockedOn = &tm
 			}
 		}
+		bagAchievement.Points = d.scorer.Points(bagAchievement.GlobalPercentage)
+		ret.PossiblePoints += bagAchievement.Points
 		if bagAchievement.Achieved {
 			ret.AchievementUnlockedCount++
+			ret.Points += bagAchievement.Points
 		}
 
 		bagAchievement.Icon = gameAchievement.Icon
@@ -255,6 +311,68 @@ func (d *Data) GetAchievements(ctx context.Context, userID string, gameID uint64
 	}
 
 	ret.AchievementUnlockedPercentage = int((float64(ret.AchievementUnlockedCount) / float64(ret.AchievementTotalCount)) * 100)
+
+	game, err := d.GetGame(ctx, userID, gameID)
+	if err != nil {
+		log.Warn("Unable to get playtime for game. Skipping completion estimate.", "err", err)
+	} else {
+		ret.Estimate = estimateCompletion(game.PlaytimeForever, ret.Achievements)
+	}
+
+	return ret, nil
+}
+
+// achievementsConcurrency bounds how many games have their achievements loaded
+// at once when loading them for every game a user has played.
+const achievementsConcurrency = 8
+
+// GameAchievements pairs a game with the user's achievements in it.
+type GameAchievements struct {
+	Game         Game
+	Achievements Achievements
+}
+
+// GetAllAchievements loads the user's achievements for every game they have
+// played that has any, fanning out across games with bounded concurrency.
+// Games whose achievements cannot be loaded are logged and skipped.
+func (d *Data) GetAllAchievements(ctx context.Context, userID string) ([]GameAchievements, error) {
+	log := slog.With("steam-id", userID)
+
+	games, err := d.GetGames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []GameAchievements{}
+	mx := sync.Mutex{}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(achievementsConcurrency)
+	for _, game := range games {
+		g.Go(func() error {
+			achievements, err := d.GetAchievements(ctx, userID, game.ID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+
+				log.Warn("Unable to get achievements for game. Skipping.", "game-id", game.ID, "error", err)
+				return nil
+			} else if achievements.AchievementTotalCount == 0 {
+				return nil
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+			ret = append(ret, GameAchievements{Game: game, Achievements: achievements})
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
 	return ret, nil
 }
 
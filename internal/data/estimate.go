@@ -0,0 +1,81 @@
+package data
+
+import (
+	"math"
+	"time"
+)
+
+// CompletionEstimate projects how much more a user needs to play a game to
+// unlock all of its achievements. It is a rough extrapolation of their
+// progress so far, and is always presented as an estimate alongside its
+// inputs.
+type CompletionEstimate struct {
+	// RemainingHours is the estimated playtime needed to unlock every locked
+	// achievement.
+	RemainingHours float64
+	// RemainingDays is the estimated calendar time needed at the user's pace
+	// of unlocks so far, or 0 if there are too few unlock times to tell.
+	RemainingDays float64
+
+	// The inputs used for the estimate
+	HoursPlayed float64
+	Unlocked    int
+	Locked      int
+	// Difficulty is how much harder the locked achievements are than the
+	// unlocked ones, on average, by their global rarity.
+	Difficulty float64
+	// FirstUnlock and LastUnlock bound the user's unlocks with known times.
+	FirstUnlock time.Time
+	LastUnlock  time.Time
+}
+
+// estimateCompletion extrapolates the user's progress in a game. Each
+// achievement is weighted by its rarity, so that the rare achievements that
+// typically remain take proportionally longer than those already unlocked.
+//
+// Nil is returned when there is nothing to extrapolate from, or nothing left
+// to unlock.
+func estimateCompletion(playtime time.Duration, achievements []Achievement) *CompletionEstimate {
+	ret := &CompletionEstimate{HoursPlayed: playtime.Hours()}
+
+	unlockedWeight, lockedWeight := 0.0, 0.0
+	for _, achievement := range achievements {
+		weight := estimateWeight(achievement.GlobalPercentage)
+		if !achievement.Achieved {
+			ret.Locked++
+			lockedWeight += weight
+			continue
+		}
+
+		ret.Unlocked++
+		unlockedWeight += weight
+		if on := achievement.UnlockedOn; on != nil {
+			if ret.FirstUnlock.IsZero() || on.Before(ret.FirstUnlock) {
+				ret.FirstUnlock = *on
+			}
+			if on.After(ret.LastUnlock) {
+				ret.LastUnlock = *on
+			}
+		}
+	}
+
+	if ret.Unlocked == 0 || ret.Locked == 0 || playtime <= 0 {
+		return nil
+	}
+
+	ret.Difficulty = (lockedWeight / float64(ret.Locked)) / (unlockedWeight / float64(ret.Unlocked))
+	ret.RemainingHours = ret.HoursPlayed / unlockedWeight * lockedWeight
+
+	// The pace of unlocks is only meaningful across more than a single day
+	if span := ret.LastUnlock.Sub(ret.FirstUnlock); span >= time.Hour*24 {
+		ret.RemainingDays = span.Hours() / 24 / unlockedWeight * lockedWeight
+	}
+
+	return ret
+}
+
+// estimateWeight is the relative effort of unlocking an achievement, growing
+// with the square root of its rarity.
+func estimateWeight(globalPercentage float64) float64 {
+	return math.Sqrt(100 / clampPercentage(globalPercentage))
+}
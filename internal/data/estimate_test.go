@@ -0,0 +1,74 @@
+package data
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestEstimateCompletion(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	unlocked := func(pct float64, on time.Time) Achievement {
+		return Achievement{Achieved: true, UnlockedOn: &on, GlobalPercentage: pct}
+	}
+	locked := func(pct float64) Achievement {
+		return Achievement{GlobalPercentage: pct}
+	}
+
+	tests := []struct {
+		name         string
+		playtime     time.Duration
+		achievements []Achievement
+		// want is nil when no estimate is expected.
+		want *CompletionEstimate
+	}{
+		{
+			name:         "rarer achievements take longer",
+			playtime:     time.Hour * 10,
+			achievements: []Achievement{unlocked(100, start), unlocked(100, start.AddDate(0, 0, 4)), locked(25)},
+			want:         &CompletionEstimate{RemainingHours: 10, RemainingDays: 4, HoursPlayed: 10, Unlocked: 2, Locked: 1, Difficulty: 2, FirstUnlock: start, LastUnlock: start.AddDate(0, 0, 4)},
+		},
+		{
+			name:         "unlocked within a day",
+			playtime:     time.Hour * 2,
+			achievements: []Achievement{unlocked(50, start), unlocked(50, start.Add(time.Hour)), locked(50), locked(50)},
+			want:         &CompletionEstimate{RemainingHours: 2, HoursPlayed: 2, Unlocked: 2, Locked: 2, Difficulty: 1, FirstUnlock: start, LastUnlock: start.Add(time.Hour)},
+		},
+		{
+			name:         "nothing unlocked",
+			playtime:     time.Hour,
+			achievements: []Achievement{locked(50)},
+		},
+		{
+			name:         "nothing locked",
+			playtime:     time.Hour,
+			achievements: []Achievement{unlocked(50, start)},
+		},
+		{
+			name:         "never played",
+			achievements: []Achievement{unlocked(50, start), locked(50)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := estimateCompletion(tt.playtime, tt.achievements)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("got %+v, want no estimate", *got)
+				}
+				return
+			} else if got == nil {
+				t.Fatalf("got no estimate, want %+v", *tt.want)
+			}
+
+			// Rounded, as the weights are square roots
+			got.RemainingHours = math.Round(got.RemainingHours*100) / 100
+			got.RemainingDays = math.Round(got.RemainingDays*100) / 100
+			got.Difficulty = math.Round(got.Difficulty*100) / 100
+			if *got != *tt.want {
+				t.Errorf("got %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
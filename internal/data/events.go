@@ -0,0 +1,272 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/history"
+)
+
+// EventKind identifies the type of an Event.
+type EventKind string
+
+const (
+	EventAchievementsUnlocked EventKind = "achievements.unlocked"
+	EventFirstUnlock          EventKind = "milestone.first-unlock"
+	EventPerfectGame          EventKind = "milestone.perfect-game"
+	EventUnlockTotal          EventKind = "milestone.unlock-total"
+	EventRarestUnlock         EventKind = "milestone.rarest-unlock"
+)
+
+// Event is something that happened to a user's achievements. Each kind of
+// event is its own type, which subscribers may switch on or subscribe to
+// directly through SubscribeTo.
+type Event interface {
+	Kind() EventKind
+	// User is the Steam ID of the user the event happened to.
+	User() string
+}
+
+// Milestone is an Event marking a notable point in a user's progress.
+type Milestone interface {
+	Event
+	// Description completes a sentence beginning with the user's name, such as
+	// "completed every achievement in Portal".
+	Description() string
+}
+
+// EventBase holds the fields common to every event.
+type EventBase struct {
+	SteamID string
+	At      time.Time
+}
+
+func (e EventBase) User() string {
+	return e.SteamID
+}
+
+// AchievementsUnlocked is published as new unlocks are recorded in a user's
+// history.
+type AchievementsUnlocked struct {
+	EventBase
+	GameID  uint64
+	Unlocks []history.Event
+}
+
+func (AchievementsUnlocked) Kind() EventKind { return EventAchievementsUnlocked }
+
+// FirstUnlock is published when a user unlocks their first achievement in a
+// game.
+type FirstUnlock struct {
+	EventBase
+	Game Game
+}
+
+func (FirstUnlock) Kind() EventKind { return EventFirstUnlock }
+
+func (e FirstUnlock) Description() string {
+	return fmt.Sprintf("unlocked their first achievement in %s", e.Game.DisplayName)
+}
+
+// PerfectGame is published when a user unlocks every achievement in a game.
+type PerfectGame struct {
+	EventBase
+	Game  Game
+	Total int
+}
+
+func (PerfectGame) Kind() EventKind { return EventPerfectGame }
+
+func (e PerfectGame) Description() string {
+	return fmt.Sprintf("completed all %d achievements in %s", e.Total, e.Game.DisplayName)
+}
+
+// UnlockTotal is published when the number of achievements a user has
+// unlocked across every game crosses one of unlockTotalThresholds.
+type UnlockTotal struct {
+	EventBase
+	Total int
+}
+
+func (UnlockTotal) Kind() EventKind { return EventUnlockTotal }
+
+func (e UnlockTotal) Description() string {
+	return fmt.Sprintf("has unlocked %d achievements", e.Total)
+}
+
+// RarestUnlock is published when a user unlocks an achievement rarer than any
+// they had unlocked before.
+type RarestUnlock struct {
+	EventBase
+	Game        Game
+	Achievement Achievement
+}
+
+func (RarestUnlock) Kind() EventKind { return EventRarestUnlock }
+
+func (e RarestUnlock) Description() string {
+	return fmt.Sprintf("unlocked their rarest achievement yet, %s in %s (%.1f%% of players)",
+		e.Achievement.Name, e.Game.DisplayName, e.Achievement.GlobalPercentage)
+}
+
+// Bus distributes events to subscribers within the process.
+type Bus struct {
+	subscribers map[int]func(context.Context, Event)
+	next        int
+	mx          sync.RWMutex
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: map[int]func(context.Context, Event){}}
+}
+
+// Subscribe calls the handler with every event published, until the returned
+// function is called.
+func (b *Bus) Subscribe(handler func(context.Context, Event)) (unsubscribe func()) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	id := b.next
+	b.next++
+	b.subscribers[id] = handler
+
+	return func() {
+		b.mx.Lock()
+		defer b.mx.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// SubscribeTo calls the handler with every event of type E published, until
+// the returned function is called.
+func SubscribeTo[E Event](b *Bus, handler func(context.Context, E)) (unsubscribe func()) {
+	return b.Subscribe(func(ctx context.Context, event Event) {
+		if e, ok := event.(E); ok {
+			handler(ctx, e)
+		}
+	})
+}
+
+// Publish calls every subscriber with the event, in turn. Subscribers are
+// called on the publisher's goroutine, so should hand any slow work off to
+// the background.
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	b.mx.RLock()
+	handlers := make([]func(context.Context, Event), 0, len(b.subscribers))
+	for _, handler := range b.subscribers {
+		handlers = append(handlers, handler)
+	}
+	b.mx.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
+
+// Events returns the bus that the data layer publishes events to.
+func (d *Data) Events() *Bus {
+	return d.events
+}
+
+// unlockTotalThresholds are the account-wide unlock totals that are
+// milestones. Every multiple of the last threshold beyond it is one as well.
+var unlockTotalThresholds = []int{100, 500, 1000}
+
+// crossedThresholds returns the unlock total milestones within (from, to].
+func crossedThresholds(from, to int) []int {
+	ret := []int{}
+	last := unlockTotalThresholds[len(unlockTotalThresholds)-1]
+	for _, threshold := range unlockTotalThresholds {
+		if from < threshold && threshold <= to {
+			ret = append(ret, threshold)
+		}
+	}
+	for threshold := (from/last + 1) * last; threshold <= to; threshold += last {
+		if threshold > last {
+			ret = append(ret, threshold)
+		}
+	}
+	return ret
+}
+
+// reachedThreshold returns the highest unlock total milestone at or below the
+// total, or 0 if none has been reached.
+func reachedThreshold(total int) int {
+	last := unlockTotalThresholds[len(unlockTotalThresholds)-1]
+	if total >= last {
+		return total / last * last
+	}
+
+	ret := 0
+	for _, threshold := range unlockTotalThresholds {
+		if threshold <= total {
+			ret = threshold
+		}
+	}
+	return ret
+}
+
+// unlockedTotal counts the achievements unlocked across every game.
+func unlockedTotal(games map[uint64]GameProgress) int {
+	ret := 0
+	for _, progress := range games {
+		ret += progress.Unlocked
+	}
+	return ret
+}
+
+// detectMilestones compares a user's progress before and after their summary
+// was updated, returning the milestones reached in between. Games missing from
+// the previous progress, such as those that could not be loaded for it, are
+// not compared, as there is no telling what in them is new. Unlock totals
+// are only milestones above the highest threshold already reached.
+func detectMilestones(userID string, previous, current map[uint64]GameProgress, games map[uint64]Game, reached int, now time.Time) []Milestone {
+	base := EventBase{SteamID: userID, At: now}
+	ret := []Milestone{}
+
+	var previousRarest, currentRarest *Achievement
+	var rarestGame uint64
+	for _, progress := range previous {
+		if progress.Rarest != nil && (previousRarest == nil || progress.Rarest.GlobalPercentage < previousRarest.GlobalPercentage) {
+			previousRarest = progress.Rarest
+		}
+	}
+
+	for id, progress := range current {
+		before, ok := previous[id]
+		if !ok {
+			continue
+		}
+
+		if progress.Rarest != nil && (currentRarest == nil || progress.Rarest.GlobalPercentage < currentRarest.GlobalPercentage) {
+			currentRarest = progress.Rarest
+			rarestGame = id
+		}
+
+		if before.Unlocked == 0 && progress.Unlocked > 0 {
+			ret = append(ret, FirstUnlock{EventBase: base, Game: games[id]})
+		}
+		if progress.Total > 0 && progress.Unlocked == progress.Total && before.Unlocked < progress.Total {
+			ret = append(ret, PerfectGame{EventBase: base, Game: games[id], Total: progress.Total})
+		}
+	}
+
+	// Summaries stored before thresholds were recorded fall back upon the
+	// previous total
+	from := max(reached, unlockedTotal(previous))
+	for _, total := range crossedThresholds(from, unlockedTotal(current)) {
+		ret = append(ret, UnlockTotal{EventBase: base, Total: total})
+	}
+
+	// Global percentages drift over time, so the rarest achievement must also
+	// be newly unlocked to count
+	if currentRarest != nil && (previousRarest == nil || currentRarest.GlobalPercentage < previousRarest.GlobalPercentage) {
+		if before := previous[rarestGame].Rarest; before == nil || before.Name != currentRarest.Name {
+			ret = append(ret, RarestUnlock{EventBase: base, Game: games[rarestGame], Achievement: *currentRarest})
+		}
+	}
+
+	return ret
+}
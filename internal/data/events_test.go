@@ -0,0 +1,74 @@
+package data
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDetectMilestones(t *testing.T) {
+	games := map[uint64]Game{1: {ID: 1}, 2: {ID: 2}}
+	rare := &Achievement{Name: "Rare", GlobalPercentage: 1}
+
+	tests := []struct {
+		name     string
+		previous map[uint64]GameProgress
+		current  map[uint64]GameProgress
+		reached  int
+		want     []EventKind
+	}{
+		{
+			name:     "first unlock and perfect game",
+			previous: map[uint64]GameProgress{1: {Unlocked: 0, Total: 2}},
+			current:  map[uint64]GameProgress{1: {Unlocked: 2, Total: 2}},
+			want:     []EventKind{EventFirstUnlock, EventPerfectGame},
+		},
+		{
+			name:     "game missing from the previous progress",
+			previous: map[uint64]GameProgress{1: {Unlocked: 1, Total: 2}},
+			current:  map[uint64]GameProgress{1: {Unlocked: 1, Total: 2}, 2: {Unlocked: 2, Total: 2, Rarest: rare}},
+			want:     []EventKind{},
+		},
+		{
+			name:     "unlock total crossed",
+			previous: map[uint64]GameProgress{1: {Unlocked: 99, Total: 200}},
+			current:  map[uint64]GameProgress{1: {Unlocked: 101, Total: 200}},
+			want:     []EventKind{EventUnlockTotal},
+		},
+		{
+			name:     "unlock total crossed again once games return",
+			previous: map[uint64]GameProgress{1: {Unlocked: 50, Total: 200}},
+			current:  map[uint64]GameProgress{1: {Unlocked: 50, Total: 200}, 2: {Unlocked: 60, Total: 60}},
+			reached:  100,
+			want:     []EventKind{},
+		},
+		{
+			name:     "rarest unlock",
+			previous: map[uint64]GameProgress{1: {Unlocked: 1, Total: 3}},
+			current:  map[uint64]GameProgress{1: {Unlocked: 2, Total: 3, Rarest: rare}},
+			want:     []EventKind{EventRarestUnlock},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []EventKind{}
+			for _, milestone := range detectMilestones("1", tt.previous, tt.current, games, tt.reached, time.Now()) {
+				got = append(got, milestone.Kind())
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReachedThreshold(t *testing.T) {
+	tests := map[int]int{0: 0, 99: 0, 100: 100, 499: 100, 500: 500, 1999: 1000, 3500: 3000}
+	for total, want := range tests {
+		if got := reachedThreshold(total); got != want {
+			t.Errorf("reachedThreshold(%d) = %d, want %d", total, got, want)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package data
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat is the file format that achievements are exported in.
+type ExportFormat string
+
+const (
+	// ExportCSV writes a header row followed by a row per achievement.
+	ExportCSV ExportFormat = "csv"
+	// ExportJSON writes a single array of ExportRow objects.
+	ExportJSON ExportFormat = "json"
+	// ExportNDJSON writes an ExportRow object per line.
+	ExportNDJSON ExportFormat = "ndjson"
+)
+
+// ExportFormats lists every supported format.
+var ExportFormats = []ExportFormat{ExportCSV, ExportJSON, ExportNDJSON}
+
+// ParseExportFormat returns the format with the given name.
+func ParseExportFormat(name string) (ExportFormat, error) {
+	format := ExportFormat(name)
+	if !slices.Contains(ExportFormats, format) {
+		return "", fmt.Errorf("unknown export format %q", name)
+	}
+	return format, nil
+}
+
+// ContentType is the MIME type of the format.
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportCSV:
+		return "text/csv; charset=utf-8"
+	case ExportNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/json"
+	}
+}
+
+// ExportScope selects the games that are exported.
+type ExportScope struct {
+	// GameID limits the export to a single game. Every game is exported
+	// when it is zero.
+	GameID uint64
+}
+
+// ParseExportScope parses "all" or "game:{id}" into a scope.
+func ParseExportScope(scope string) (ExportScope, error) {
+	if scope == "all" {
+		return ExportScope{}, nil
+	}
+
+	if id, ok := strings.CutPrefix(scope, "game:"); ok {
+		gameID, err := strconv.ParseUint(id, 10, 64)
+		if err == nil && gameID > 0 {
+			return ExportScope{GameID: gameID}, nil
+		}
+	}
+
+	return ExportScope{}, fmt.Errorf(`unknown export scope %q. Must be "all" or "game:{id}"`, scope)
+}
+
+// ExportRow is a single achievement in an export, alongside its game.
+type ExportRow struct {
+	AppID            uint64     `json:"app_id"`
+	Game             string     `json:"game"`
+	PlaytimeMinutes  int64      `json:"playtime_minutes"`
+	APIName          string     `json:"api_name"`
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	Unlocked         bool       `json:"unlocked"`
+	UnlockTime       *time.Time `json:"unlock_time"`
+	GlobalPercentage float64    `json:"global_percent"`
+}
+
+var exportColumns = []string{"app_id", "game", "playtime_minutes", "api_name", "name", "description", "unlocked", "unlock_time", "global_percent"}
+
+func (r ExportRow) record() []string {
+	unlockTime := ""
+	if r.UnlockTime != nil {
+		unlockTime = r.UnlockTime.Format(time.RFC3339)
+	}
+
+	return []string{
+		strconv.FormatUint(r.AppID, 10),
+		r.Game,
+		strconv.FormatInt(r.PlaytimeMinutes, 10),
+		r.APIName,
+		r.Name,
+		r.Description,
+		strconv.FormatBool(r.Unlocked),
+		unlockTime,
+		strconv.FormatFloat(r.GlobalPercentage, 'f', -1, 64),
+	}
+}
+
+// Export streams a user's achievements in the games of a scope.
+type Export struct {
+	data   *Data
+	userID string
+	games  []Game
+}
+
+// NewExport resolves the games to be exported, so that a missing user or game
+// is reported before anything is written.
+func (d *Data) NewExport(ctx context.Context, userID string, scope ExportScope) (*Export, error) {
+	games, err := d.GetGames(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if scope.GameID != 0 {
+		games = slices.DeleteFunc(games, func(game Game) bool { return game.ID != scope.GameID })
+		if len(games) == 0 {
+			return nil, fmt.Errorf("user %q has not played game %d", userID, scope.GameID)
+		}
+	}
+
+	slices.SortFunc(games, func(a, b Game) int {
+		return strings.Compare(strings.ToLower(a.DisplayName), strings.ToLower(b.DisplayName))
+	})
+
+	return &Export{data: d, userID: userID, games: games}, nil
+}
+
+// Write writes the export to w in the given format, one game at a time.
+// Achievements are loaded a few games ahead of the game being written, so that
+// only those games are held in memory at once. Games whose achievements cannot
+// be loaded are logged and skipped.
+//
+// If w has a Flush method, such as an http.ResponseWriter, it is called after
+// each game.
+func (e *Export) Write(ctx context.Context, w io.Writer, format ExportFormat) error {
+	writer, err := newExportWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type loaded struct {
+		game         Game
+		achievements Achievements
+		err          error
+	}
+
+	// Each game is loaded concurrently, with the window bounded by the
+	// capacity of pending
+	pending := make(chan chan loaded, achievementsConcurrency)
+	go func() {
+		defer close(pending)
+		for _, game := range e.games {
+			result := make(chan loaded, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return
+			}
+
+			go func() {
+				achievements, err := e.data.GetAchievements(ctx, e.userID, game.ID)
+				result <- loaded{game: game, achievements: achievements, err: err}
+			}()
+		}
+	}()
+
+	if err := writer.begin(); err != nil {
+		return err
+	}
+
+	for result := range pending {
+		game := <-result
+		if game.err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			slog.Warn("Unable to export game. Skipping.", "steam-id", e.userID, "game-id", game.game.ID, "error", game.err)
+			continue
+		}
+
+		for _, achievement := range game.achievements.Achievements {
+			row := ExportRow{
+				AppID:            game.game.ID,
+				Game:             game.game.DisplayName,
+				PlaytimeMinutes:  int64(game.game.PlaytimeForever / time.Minute),
+				APIName:          achievement.APIName,
+				Name:             achievement.Name,
+				Description:      achievement.Description,
+				Unlocked:         achievement.Achieved,
+				GlobalPercentage: achievement.GlobalPercentage,
+			}
+			if achievement.UnlockedOn != nil {
+				unlockTime := achievement.UnlockedOn.UTC()
+				row.UnlockTime = &unlockTime
+			}
+			if err := writer.row(row); err != nil {
+				return err
+			}
+		}
+
+		if err := writer.flush(); err != nil {
+			return err
+		}
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return writer.end()
+}
+
+// exportWriter encodes export rows in a single format.
+type exportWriter interface {
+	begin() error
+	row(ExportRow) error
+	// flush writes any buffered rows through to the underlying writer.
+	flush() error
+	end() error
+}
+
+func newExportWriter(w io.Writer, format ExportFormat) (exportWriter, error) {
+	switch format {
+	case ExportCSV:
+		return &csvExportWriter{w: csv.NewWriter(w)}, nil
+	case ExportJSON:
+		return &jsonExportWriter{w: w}, nil
+	case ExportNDJSON:
+		return &ndjsonExportWriter{enc: json.NewEncoder(w)}, nil
+	}
+	return nil, fmt.Errorf("unknown export format %q", format)
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvExportWriter) begin() error {
+	return c.w.Write(exportColumns)
+}
+
+func (c *csvExportWriter) row(row ExportRow) error {
+	record := row.record()
+	for i, cell := range record {
+		record[i] = csvCell(cell)
+	}
+	return c.w.Write(record)
+}
+
+// csvCell prefixes cells that spreadsheets would otherwise run as a formula,
+// such as a game named "=HYPERLINK(...)", so that they are shown as text.
+func csvCell(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+func (c *csvExportWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvExportWriter) end() error {
+	return c.flush()
+}
+
+// jsonExportWriter writes rows as the elements of a single array, without
+// holding the array in memory.
+type jsonExportWriter struct {
+	w     io.Writer
+	first bool
+}
+
+func (j *jsonExportWriter) begin() error {
+	j.first = true
+	_, err := io.WriteString(j.w, "[")
+	return err
+}
+
+func (j *jsonExportWriter) row(row ExportRow) error {
+	line, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	sep := ",\n"
+	if j.first {
+		sep = "\n"
+		j.first = false
+	}
+	_, err = io.WriteString(j.w, sep+string(line))
+	return err
+}
+
+func (j *jsonExportWriter) flush() error {
+	return nil
+}
+
+func (j *jsonExportWriter) end() error {
+	_, err := io.WriteString(j.w, "\n]\n")
+	return err
+}
+
+type ndjsonExportWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonExportWriter) begin() error {
+	return nil
+}
+
+func (n *ndjsonExportWriter) row(row ExportRow) error {
+	return n.enc.Encode(row)
+}
+
+func (n *ndjsonExportWriter) flush() error {
+	return nil
+}
+
+func (n *ndjsonExportWriter) end() error {
+	return nil
+}
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExport(t *testing.T) {
+	f := newFakeSteam()
+	now := time.Now()
+	f.users["1"] = []fakeGame{
+		{ID: 1, Name: "Portal", Achievements: 2, Unlocked: 1, UnlockedAt: now.Add(-time.Hour), LastPlayed: now},
+		{ID: 2, Name: "=HYPERLINK(\"http://example.com\")", Achievements: 1, LastPlayed: now},
+	}
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	export, err := d.NewExport(ctx, "1", ExportScope{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		out := &strings.Builder{}
+		if err := export.Write(ctx, out, ExportCSV); err != nil {
+			t.Fatal(err)
+		}
+
+		records, err := csv.NewReader(strings.NewReader(out.String())).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 4 {
+			t.Fatalf("got %d records, want a header and 3 achievements", len(records))
+		}
+
+		// Games are sorted by name, and formulas are written as text
+		if got, want := records[1][1], `'=HYPERLINK("http://example.com")`; got != want {
+			t.Errorf("got game %q, want %q", got, want)
+		}
+		if got := records[2][1]; got != "Portal" {
+			t.Errorf("got game %q, want Portal", got)
+		}
+		if records[2][6] != "true" || records[2][7] == "" {
+			t.Errorf("got %v, want the first Portal achievement unlocked", records[2])
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out := &strings.Builder{}
+		if err := export.Write(ctx, out, ExportJSON); err != nil {
+			t.Fatal(err)
+		}
+
+		rows := []ExportRow{}
+		if err := json.Unmarshal([]byte(out.String()), &rows); err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 3 {
+			t.Fatalf("got %d rows, want 3", len(rows))
+		}
+		if got, want := rows[0].Game, `=HYPERLINK("http://example.com")`; got != want {
+			t.Errorf("got game %q, want %q unchanged", got, want)
+		}
+	})
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"":             "",
+		"Portal":       "Portal",
+		"=1+1":         "'=1+1",
+		"+1":           "'+1",
+		"-1":           "'-1",
+		"@SUM(A1)":     "'@SUM(A1)",
+		"\tTabbed":     "'\tTabbed",
+		"\rReturned":   "'\rReturned",
+		"Half-Life 2":  "Half-Life 2",
+		"user@example": "user@example",
+	}
+
+	for cell, want := range tests {
+		if got := csvCell(cell); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", cell, got, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+	"github.com/taiidani/achievements/internal/steam"
+)
+
+// fakeGame is a game owned by a fake Steam user.
+type fakeGame struct {
+	ID           uint64
+	Name         string
+	Achievements int
+	// Unlocked is the number of achievements unlocked, each at UnlockedAt.
+	Unlocked   int
+	UnlockedAt time.Time
+	LastPlayed time.Time
+}
+
+// fakeSteam serves the Steam API endpoints used by this package from memory.
+type fakeSteam struct {
+	mx    sync.Mutex
+	users map[string][]fakeGame
+	// status overrides the response to any request for the app ID.
+	status map[uint64]int
+	// storeStatus overrides the response to every store request, if set.
+	storeStatus int
+	// delay is waited before serving each request, if set.
+	delay time.Duration
+	// requests counts the requests made to each endpoint.
+	requests map[string]int
+}
+
+func newFakeSteam() *fakeSteam {
+	return &fakeSteam{
+		users:    map[string][]fakeGame{},
+		status:   map[uint64]int{},
+		requests: map[string]int{},
+	}
+}
+
+// newTestData builds the data layer against the fake Steam API and an
+// in-memory cache. Tests using it must not run in parallel, as the fake is
+// installed as the default HTTP transport.
+func newTestData(t *testing.T, f *fakeSteam) *Data {
+	t.Helper()
+
+	original := http.DefaultClient.Transport
+	http.DefaultClient.Transport = f
+	t.Cleanup(func() { http.DefaultClient.Transport = original })
+
+	return NewData(steam.NewClient(), cache.NewMemory(), nil)
+}
+
+func (f *fakeSteam) count(endpoint string) int {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	return f.requests[endpoint]
+}
+
+func (f *fakeSteam) game(steamID string, appID uint64) (fakeGame, bool) {
+	for _, game := range f.users[steamID] {
+		if game.ID == appID {
+			return game, true
+		}
+	}
+	for _, games := range f.users {
+		for _, game := range games {
+			if game.ID == appID {
+				return fakeGame{ID: appID, Name: game.Name, Achievements: game.Achievements}, false
+			}
+		}
+	}
+	return fakeGame{}, false
+}
+
+func (f *fakeSteam) RoundTrip(r *http.Request) (*http.Response, error) {
+	time.Sleep(f.delay)
+
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	endpoint := r.URL.Path
+	for _, name := range []string{"GetOwnedGames", "GetSchemaForGame", "GetGlobalAchievementPercentagesForApp", "GetPlayerAchievements", "GetPlayerSummaries", "appdetails"} {
+		if strings.Contains(r.URL.Path, name) {
+			endpoint = name
+		}
+	}
+	f.requests[endpoint]++
+
+	query := r.URL.Query()
+	steamID := query.Get("steamid")
+	appID, _ := strconv.ParseUint(query.Get("appid")+query.Get("appids")+query.Get("gameid"), 10, 64)
+
+	w := httptest.NewRecorder()
+	if status, ok := f.status[appID]; ok && appID != 0 {
+		w.WriteHeader(status)
+		_, _ = w.WriteString(`{}`)
+		return w.Result(), nil
+	}
+
+	if f.storeStatus != 0 && endpoint == "appdetails" {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(f.storeStatus)
+		return w.Result(), nil
+	}
+
+	var body any
+	switch endpoint {
+	case "GetOwnedGames":
+		games := []map[string]any{}
+		for _, game := range f.users[steamID] {
+			games = append(games, map[string]any{
+				"appid":             game.ID,
+				"name":              game.Name,
+				"playtime_forever":  60,
+				"rtime_last_played": game.LastPlayed.Unix(),
+			})
+		}
+		body = map[string]any{"response": map[string]any{"game_count": len(games), "games": games}}
+	case "GetPlayerSummaries":
+		players := []map[string]any{}
+		for _, id := range strings.Split(query.Get("steamids"), ",") {
+			if _, ok := f.users[id]; ok {
+				players = append(players, map[string]any{"steamid": id, "personaname": "user " + id})
+			}
+		}
+		body = map[string]any{"response": map[string]any{"players": players}}
+	case "GetSchemaForGame":
+		game, _ := f.game("", appID)
+		achievements := []map[string]any{}
+		for i := range game.Achievements {
+			achievements = append(achievements, map[string]any{"name": fmt.Sprintf("A%d", i), "displayName": fmt.Sprintf("Achievement %d", i)})
+		}
+		body = map[string]any{"game": map[string]any{"gameName": game.Name, "availableGameStats": map[string]any{"achievements": achievements}}}
+	case "GetGlobalAchievementPercentagesForApp":
+		game, _ := f.game("", appID)
+		achievements := []map[string]any{}
+		for i := range game.Achievements {
+			achievements = append(achievements, map[string]any{"name": fmt.Sprintf("A%d", i), "percent": 50})
+		}
+		body = map[string]any{"achievementpercentages": map[string]any{"achievements": achievements}}
+	case "GetPlayerAchievements":
+		game, owned := f.game(steamID, appID)
+		if !owned {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.WriteString(`{"playerstats":{"error":"Requested app has no stats","success":false}}`)
+			return w.Result(), nil
+		}
+		achievements := []map[string]any{}
+		for i := range game.Achievements {
+			achievement := map[string]any{"apiname": fmt.Sprintf("A%d", i)}
+			if i < game.Unlocked {
+				achievement["achieved"] = 1
+				achievement["unlocktime"] = game.UnlockedAt.Unix()
+			}
+			achievements = append(achievements, achievement)
+		}
+		body = map[string]any{"playerstats": map[string]any{"steamid": steamID, "achievements": achievements, "success": true}}
+	case "appdetails":
+		game, _ := f.game("", appID)
+		body = map[string]any{strconv.FormatUint(appID, 10): map[string]any{
+			"success": true,
+			"data":    map[string]any{"name": game.Name, "steam_appid": appID, "genres": []map[string]any{{"id": "1", "description": "Action"}}},
+		}}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return w.Result(), nil
+	}
+
+	_ = json.NewEncoder(w).Encode(body)
+	return w.Result(), nil
+}
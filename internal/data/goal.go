@@ -0,0 +1,652 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+	"golang.org/x/sync/errgroup"
+)
+
+// GoalKind is the challenge that a goal sets.
+type GoalKind string
+
+const (
+	// GoalCompleteGames is met once every achievement in each of its games
+	// has been unlocked.
+	GoalCompleteGames GoalKind = "complete-games"
+	// GoalUnlockCount is met once its Target of achievements have been
+	// unlocked between its Start and Deadline, across any games.
+	GoalUnlockCount GoalKind = "unlock-count"
+)
+
+// GoalKinds lists every kind of goal.
+var GoalKinds = []GoalKind{GoalCompleteGames, GoalUnlockCount}
+
+// Goal is a challenge set by a user for themselves, or by a group's owner for
+// each of its members.
+type Goal struct {
+	ID    string
+	Kind  GoalKind
+	Title string
+	// GameIDs are the games to complete for a GoalCompleteGames goal.
+	GameIDs []uint64
+	// Target is the number of achievements to unlock for a GoalUnlockCount
+	// goal.
+	Target int
+	// Start is when the goal's deadline is counted from, and the earliest
+	// unlock counted towards a GoalUnlockCount goal.
+	Start time.Time
+	// Deadline is when the goal is due, if it has one.
+	Deadline  time.Time
+	CreatedAt time.Time
+}
+
+const (
+	// maxGoals bounds how many goals each user and group may set.
+	maxGoals = 20
+	// maxGoalTitle bounds the length of goal titles.
+	maxGoalTitle = 64
+	// maxGoalTarget bounds the number of achievements a goal may target.
+	maxGoalTarget = 100000
+)
+
+var ErrTooManyGoals = fmt.Errorf("no more than %d goals may be set", maxGoals)
+
+// GoalStatus describes how a goal is progressing towards its deadline.
+type GoalStatus string
+
+const (
+	// GoalInProgress goals have no deadline and are not yet complete.
+	GoalInProgress GoalStatus = "in-progress"
+	// GoalOnTrack goals are progressing at least as fast as their deadline
+	// requires.
+	GoalOnTrack GoalStatus = "on-track"
+	// GoalBehind goals are progressing too slowly to meet their deadline.
+	GoalBehind GoalStatus = "behind"
+	// GoalComplete goals were met, by their deadline if they have one.
+	GoalComplete GoalStatus = "complete"
+	// GoalLate goals were met after their deadline.
+	GoalLate GoalStatus = "late"
+	// GoalMissed goals passed their deadline without being met.
+	GoalMissed GoalStatus = "missed"
+)
+
+// Label is the human readable name of the status.
+func (s GoalStatus) Label() string {
+	switch s {
+	case GoalOnTrack:
+		return "On track"
+	case GoalBehind:
+		return "Behind"
+	case GoalComplete:
+		return "Complete"
+	case GoalLate:
+		return "Completed late"
+	case GoalMissed:
+		return "Missed"
+	default:
+		return "In progress"
+	}
+}
+
+// GoalProgress is a user's progress towards a goal.
+type GoalProgress struct {
+	Goal Goal
+	// GroupID and GroupName are set for goals shared by a group.
+	GroupID   string
+	GroupName string
+	// Current counts the achievements unlocked towards the Target.
+	Current    int
+	Target     int
+	Percentage int
+	Status     GoalStatus
+	// CompletedAt is when the goal was met, if it has been.
+	CompletedAt time.Time
+	// DaysLeft is the number of days until the goal's deadline, if it has
+	// one and it has not passed.
+	DaysLeft int
+	// Games lists the user's progress in each game of a GoalCompleteGames
+	// goal.
+	Games []GoalGame
+	// Unavailable is set when the progress could not be computed, such as
+	// when one of the goal's games could not be loaded.
+	Unavailable bool
+}
+
+// Done reports whether the goal has been met, whether or not it was in time.
+func (p GoalProgress) Done() bool {
+	return !p.CompletedAt.IsZero()
+}
+
+type GoalGame struct {
+	Game     Game
+	Progress GameProgress
+}
+
+// GetGoals returns the goals set by the user.
+func (d *Data) GetGoals(ctx context.Context, steamID string) ([]Goal, error) {
+	ret := []Goal{}
+	err := d.cache.Get(ctx, keyPlayerGoals.Key(steamID), &ret)
+	if errors.Is(err, cache.ErrNotFound) {
+		return []Goal{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get goals for %q: %w", steamID, err)
+	}
+	return ret, nil
+}
+
+// AddGoal sets a new goal for the user. A title is generated for the goal if
+// it has none.
+func (d *Data) AddGoal(ctx context.Context, steamID string, goal Goal) (Goal, error) {
+	goal, err := d.newGoal(ctx, steamID, goal)
+	if err != nil {
+		return Goal{}, err
+	}
+
+	unlock, err := d.lock(ctx, keyPlayerGoals.Key(steamID))
+	if err != nil {
+		return Goal{}, err
+	}
+	defer unlock()
+
+	goals, err := d.GetGoals(ctx, steamID)
+	if err != nil {
+		return Goal{}, err
+	} else if len(goals) >= maxGoals {
+		return Goal{}, ErrTooManyGoals
+	}
+
+	return goal, d.setGoals(ctx, steamID, append(goals, goal))
+}
+
+// DeleteGoal removes one of the user's goals.
+func (d *Data) DeleteGoal(ctx context.Context, steamID string, id string) error {
+	unlock, err := d.lock(ctx, keyPlayerGoals.Key(steamID))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	goals, err := d.GetGoals(ctx, steamID)
+	if err != nil {
+		return err
+	}
+
+	goals = slices.DeleteFunc(goals, func(goal Goal) bool { return goal.ID == id })
+	return d.setGoals(ctx, steamID, goals)
+}
+
+// RenewGoals extends how long the user's goals are kept, as they expire along
+// with the user's sessions.
+func (d *Data) RenewGoals(ctx context.Context, steamID string) error {
+	unlock, err := d.lock(ctx, keyPlayerGoals.Key(steamID))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	goals, err := d.GetGoals(ctx, steamID)
+	if err != nil || len(goals) == 0 {
+		return err
+	}
+	return d.setGoals(ctx, steamID, goals)
+}
+
+func (d *Data) setGoals(ctx context.Context, steamID string, goals []Goal) error {
+	key := keyPlayerGoals.Key(steamID)
+	if len(goals) == 0 {
+		return d.cache.Delete(ctx, key)
+	}
+
+	if err := d.cache.Set(ctx, key, goals, DefaultSessionExpiration); err != nil {
+		return fmt.Errorf("could not save goals for %q: %w", steamID, err)
+	}
+	return nil
+}
+
+// AddGroupGoal sets a new goal for every member of the group to compete on.
+// Only the group's owner may do so.
+func (d *Data) AddGroupGoal(ctx context.Context, id string, actorID string, goal Goal) (Group, error) {
+	goal, err := d.newGoal(ctx, actorID, goal)
+	if err != nil {
+		return Group{}, err
+	}
+
+	return d.updateGroup(ctx, id, func(group *Group) error {
+		if !group.IsOwner(actorID) {
+			return ErrNotGroupOwner
+		} else if len(group.Goals) >= maxGoals {
+			return ErrTooManyGoals
+		}
+
+		group.Goals = append(group.Goals, goal)
+		return nil
+	})
+}
+
+// DeleteGroupGoal removes one of the group's goals. Only the group's owner may
+// do so.
+func (d *Data) DeleteGroupGoal(ctx context.Context, id string, actorID string, goalID string) (Group, error) {
+	return d.updateGroup(ctx, id, func(group *Group) error {
+		if !group.IsOwner(actorID) {
+			return ErrNotGroupOwner
+		}
+
+		group.Goals = slices.DeleteFunc(group.Goals, func(goal Goal) bool { return goal.ID == goalID })
+		return nil
+	})
+}
+
+// newGoal validates a goal that is being set by the user, filling in its ID,
+// creation time, start and title.
+func (d *Data) newGoal(ctx context.Context, steamID string, goal Goal) (Goal, error) {
+	now := time.Now()
+	if goal.Start.IsZero() {
+		goal.Start = now
+	}
+
+	switch goal.Kind {
+	case GoalCompleteGames:
+		if len(goal.GameIDs) == 0 || slices.Contains(goal.GameIDs, 0) {
+			return Goal{}, errors.New("choose the games to complete")
+		}
+		slices.Sort(goal.GameIDs)
+		goal.GameIDs = slices.Compact(goal.GameIDs)
+		goal.Target = 0
+	case GoalUnlockCount:
+		if goal.Target < 1 || goal.Target > maxGoalTarget {
+			return Goal{}, fmt.Errorf("the number of achievements to unlock must be between 1 and %d", maxGoalTarget)
+		}
+		goal.GameIDs = nil
+	default:
+		return Goal{}, fmt.Errorf("unknown goal kind %q", goal.Kind)
+	}
+
+	if !goal.Deadline.IsZero() && !goal.Deadline.After(goal.Start) {
+		return Goal{}, errors.New("the deadline must be after the goal starts")
+	} else if !goal.Deadline.IsZero() && goal.Deadline.Before(now) {
+		return Goal{}, errors.New("the deadline has already passed")
+	}
+
+	goal.Title = strings.TrimSpace(goal.Title)
+	if len(goal.Title) > maxGoalTitle {
+		return Goal{}, fmt.Errorf("goal titles may be no longer than %d characters", maxGoalTitle)
+	} else if goal.Title == "" {
+		goal.Title = d.goalTitle(ctx, steamID, goal)
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return Goal{}, err
+	}
+	goal.ID = id
+	goal.CreatedAt = now
+
+	return goal, nil
+}
+
+// goalTitle describes a goal that was set without a title.
+func (d *Data) goalTitle(ctx context.Context, steamID string, goal Goal) string {
+	var title string
+	switch goal.Kind {
+	case GoalUnlockCount:
+		title = fmt.Sprintf("Unlock %d achievements", goal.Target)
+	case GoalCompleteGames:
+		title = fmt.Sprintf("Complete %d games", len(goal.GameIDs))
+		if len(goal.GameIDs) == 1 {
+			title = fmt.Sprintf("Complete game %d", goal.GameIDs[0])
+			if game, err := d.GetGame(ctx, steamID, goal.GameIDs[0]); err == nil && game.DisplayName != "" {
+				title = "Complete " + game.DisplayName
+			}
+		}
+	}
+
+	if !goal.Deadline.IsZero() {
+		title += " by " + goal.Deadline.Format("Jan 2, 2006")
+	}
+	return title
+}
+
+// GetGoalProgress computes the user's progress towards the goal.
+func (d *Data) GetGoalProgress(ctx context.Context, steamID string, goal Goal) (GoalProgress, error) {
+	var ret GoalProgress
+	var err error
+	switch goal.Kind {
+	case GoalCompleteGames:
+		ret, err = d.completeGamesProgress(ctx, steamID, goal)
+	case GoalUnlockCount:
+		ret, err = d.unlockCountProgress(ctx, steamID, goal)
+	default:
+		err = fmt.Errorf("unknown goal kind %q", goal.Kind)
+	}
+	if err != nil {
+		return GoalProgress{}, fmt.Errorf("could not compute progress of goal %q for %q: %w", goal.ID, steamID, err)
+	}
+
+	ret.Goal = goal
+	if ret.Target > 0 {
+		ret.Percentage = min(100, ret.Current*100/ret.Target)
+	}
+	ret.Status = goalStatus(ret, time.Now())
+	if ret.Status == GoalOnTrack || ret.Status == GoalBehind {
+		ret.DaysLeft = int(time.Until(goal.Deadline).Hours() / 24)
+	}
+
+	return ret, nil
+}
+
+// completeGamesProgress totals the achievements unlocked in each of the goal's
+// games, which is met when every game is complete.
+func (d *Data) completeGamesProgress(ctx context.Context, steamID string, goal Goal) (GoalProgress, error) {
+	ret := GoalProgress{Games: make([]GoalGame, len(goal.GameIDs))}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(achievementsConcurrency)
+	for i, gameID := range goal.GameIDs {
+		g.Go(func() error {
+			game, err := d.GetGame(gctx, steamID, gameID)
+			if err != nil {
+				return err
+			}
+			if game.ID == 0 {
+				// The user does not own the game, and so has no progress
+				ret.Games[i] = GoalGame{Game: Game{ID: gameID}}
+				return nil
+			}
+
+			achievements, err := d.GetAchievements(gctx, steamID, gameID)
+			if err != nil {
+				return err
+			}
+
+			ret.Games[i] = GoalGame{Game: game, Progress: achievements.Progress()}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return GoalProgress{}, err
+	}
+
+	complete := true
+	for _, game := range ret.Games {
+		ret.Current += game.Progress.Unlocked
+		ret.Target += game.Progress.Total
+		complete = complete && game.Progress.Total > 0 && game.Progress.Unlocked == game.Progress.Total
+
+		if game.Progress.LastUnlock.After(ret.CompletedAt) {
+			ret.CompletedAt = game.Progress.LastUnlock
+		}
+	}
+
+	if !complete {
+		ret.CompletedAt = time.Time{}
+	} else if ret.CompletedAt.IsZero() {
+		// Steam does not report when every achievement was unlocked. The
+		// goal was at least met by the time it was set.
+		ret.CompletedAt = goal.CreatedAt
+	}
+
+	return ret, nil
+}
+
+// unlockCountProgress counts the achievements unlocked within the goal's
+// window, which is met on the unlock that reaches its target.
+func (d *Data) unlockCountProgress(ctx context.Context, steamID string, goal Goal) (GoalProgress, error) {
+	ret := GoalProgress{Target: goal.Target}
+
+	// Only the games with unlocks since the goal started need their
+	// achievements loaded
+	summary, err := d.GetSummary(ctx, steamID)
+	if err != nil {
+		return GoalProgress{}, err
+	}
+
+	unlocks := []time.Time{}
+	mx := sync.Mutex{}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(achievementsConcurrency)
+	for gameID, progress := range summary.Games {
+		if progress.LastUnlock.Before(goal.Start) {
+			continue
+		}
+
+		g.Go(func() error {
+			achievements, err := d.GetAchievements(gctx, steamID, gameID)
+			if err != nil {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+
+				slog.Warn("Unable to count goal unlocks for game. Skipping.", "steam-id", steamID, "game-id", gameID, "error", err)
+				return nil
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+			for _, achievement := range achievements.Achievements {
+				if !achievement.Achieved || achievement.UnlockedOn == nil || achievement.UnlockedOn.Before(goal.Start) {
+					continue
+				} else if !goal.Deadline.IsZero() && achievement.UnlockedOn.After(goal.Deadline) {
+					continue
+				}
+				unlocks = append(unlocks, *achievement.UnlockedOn)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return GoalProgress{}, err
+	}
+
+	slices.SortFunc(unlocks, func(a, b time.Time) int { return a.Compare(b) })
+	ret.Current = len(unlocks)
+	if ret.Current >= ret.Target {
+		ret.CompletedAt = unlocks[ret.Target-1]
+	}
+
+	return ret, nil
+}
+
+// goalStatus compares the progress made towards a goal against the time that
+// has passed towards its deadline.
+func goalStatus(progress GoalProgress, now time.Time) GoalStatus {
+	goal := progress.Goal
+	switch {
+	case progress.Done() && (goal.Deadline.IsZero() || !progress.CompletedAt.After(goal.Deadline)):
+		return GoalComplete
+	case progress.Done():
+		return GoalLate
+	case goal.Deadline.IsZero():
+		return GoalInProgress
+	case now.After(goal.Deadline):
+		return GoalMissed
+	}
+
+	elapsed := float64(now.Sub(goal.Start)) / float64(goal.Deadline.Sub(goal.Start))
+	if progress.Target > 0 && float64(progress.Current)/float64(progress.Target) < elapsed {
+		return GoalBehind
+	}
+	return GoalOnTrack
+}
+
+// GetUserGoalProgress computes the user's progress towards their own goals and
+// those of every group they belong to. Unmet goals are listed first, soonest
+// deadline first. Goals whose progress could not be computed are marked
+// Unavailable, rather than failing the rest.
+func (d *Data) GetUserGoalProgress(ctx context.Context, steamID string) ([]GoalProgress, error) {
+	goals, err := d.GetGoals(ctx, steamID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := d.GetUserGroups(ctx, steamID)
+	if err != nil {
+		return nil, err
+	}
+
+	type shared struct {
+		goal  Goal
+		group *Group
+	}
+	all := []shared{}
+	for _, goal := range goals {
+		all = append(all, shared{goal: goal})
+	}
+	for _, group := range groups {
+		for _, goal := range group.Goals {
+			all = append(all, shared{goal: goal, group: &group})
+		}
+	}
+
+	ret := make([]GoalProgress, len(all))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(leaderboardConcurrency)
+	for i, goal := range all {
+		g.Go(func() error {
+			progress, err := d.GetGoalProgress(gctx, steamID, goal.goal)
+			if err != nil {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+
+				slog.Warn("Unable to compute goal progress", "steam-id", steamID, "goal-id", goal.goal.ID, "error", err)
+				progress = GoalProgress{Goal: goal.goal, Unavailable: true}
+			}
+
+			if goal.group != nil {
+				progress.GroupID = goal.group.ID
+				progress.GroupName = goal.group.Name
+			}
+			ret[i] = progress
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	sortGoalProgress(ret)
+	return ret, nil
+}
+
+// sortGoalProgress orders unmet goals before met ones, and then by their
+// deadlines.
+func sortGoalProgress(goals []GoalProgress) {
+	sort.SliceStable(goals, func(i, j int) bool {
+		a, b := goals[i], goals[j]
+		if a.Done() != b.Done() {
+			return !a.Done()
+		} else if a.Done() {
+			return a.CompletedAt.After(b.CompletedAt)
+		} else if a.Goal.Deadline.IsZero() != b.Goal.Deadline.IsZero() {
+			return !a.Goal.Deadline.IsZero()
+		}
+		return a.Goal.Deadline.Before(b.Goal.Deadline)
+	})
+}
+
+// GroupGoalProgress ranks a group's members by their progress towards one of
+// its goals.
+type GroupGoalProgress struct {
+	Goal    Goal
+	Entries []GroupGoalEntry
+}
+
+type GroupGoalEntry struct {
+	Rank     int
+	User     User
+	Progress GoalProgress
+	// Unavailable is set when the member's progress could not be computed,
+	// such as when their profile is private. They are ranked last.
+	Unavailable bool
+}
+
+// GetGroupGoalProgress computes every member's progress towards each of the
+// group's goals. Members are ranked by who met the goal first, and then by
+// how close they are to meeting it.
+func (d *Data) GetGroupGoalProgress(ctx context.Context, group Group) ([]GroupGoalProgress, error) {
+	ret := make([]GroupGoalProgress, len(group.Goals))
+	for i, goal := range group.Goals {
+		ret[i] = GroupGoalProgress{Goal: goal, Entries: make([]GroupGoalEntry, len(group.Members))}
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(leaderboardConcurrency)
+	for i, member := range group.Members {
+		g.Go(func() error {
+			log := slog.With("group-id", group.ID, "steam-id", member)
+
+			user, err := d.GetUser(gctx, member)
+			if err != nil {
+				log.Warn("Unable to load group member", "error", err)
+				user = User{SteamID: member, Name: member}
+			}
+
+			for j, goal := range group.Goals {
+				entry := GroupGoalEntry{User: user}
+				entry.Progress, err = d.GetGoalProgress(gctx, member, goal)
+				if err != nil {
+					if gctx.Err() != nil {
+						return gctx.Err()
+					}
+
+					log.Warn("Unable to compute group goal progress", "goal-id", goal.ID, "error", err)
+					entry.Unavailable = true
+				}
+				ret[j].Entries[i] = entry
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("could not compute goals for %q: %w", group.ID, err)
+	}
+
+	for i := range ret {
+		rankGoalEntries(ret[i].Entries)
+	}
+	return ret, nil
+}
+
+// rankGoalEntries orders members by when they met the goal, and then by their
+// progress towards it. Members that have made as much progress share a rank.
+func rankGoalEntries(entries []GroupGoalEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Unavailable != b.Unavailable {
+			return !a.Unavailable
+		} else if a.Progress.Done() != b.Progress.Done() {
+			return a.Progress.Done()
+		} else if a.Progress.Done() && !a.Progress.CompletedAt.Equal(b.Progress.CompletedAt) {
+			return a.Progress.CompletedAt.Before(b.Progress.CompletedAt)
+		} else if a.Progress.Percentage != b.Progress.Percentage {
+			return a.Progress.Percentage > b.Progress.Percentage
+		}
+		return strings.ToLower(a.User.Name) < strings.ToLower(b.User.Name)
+	})
+
+	for i := range entries {
+		entry, previous := &entries[i], GroupGoalEntry{}
+		if i > 0 {
+			previous = entries[i-1]
+		}
+
+		switch {
+		case i > 0 && entry.Unavailable && previous.Unavailable:
+			entry.Rank = previous.Rank
+		case i > 0 && !entry.Unavailable && !previous.Unavailable && !entry.Progress.Done() && !previous.Progress.Done() && entry.Progress.Percentage == previous.Progress.Percentage:
+			entry.Rank = previous.Rank
+		default:
+			entry.Rank = i + 1
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package data
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestGetUserGoalProgressMarksFailedGoalsUnavailable(t *testing.T) {
+	f := newFakeSteam()
+	f.users["1"] = []fakeGame{
+		{ID: 1, Name: "Working", Achievements: 2, Unlocked: 1, UnlockedAt: time.Now(), LastPlayed: time.Now()},
+		{ID: 2, Name: "Broken", Achievements: 2, LastPlayed: time.Now()},
+	}
+	f.status[2] = http.StatusInternalServerError
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	for _, gameID := range []uint64{1, 2} {
+		if _, err := d.AddGoal(ctx, "1", Goal{Kind: GoalCompleteGames, GameIDs: []uint64{gameID}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	goals, err := d.GetUserGoalProgress(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goals) != 2 {
+		t.Fatalf("got %d goals, want 2", len(goals))
+	}
+
+	for _, goal := range goals {
+		broken := goal.Goal.GameIDs[0] == 2
+		if goal.Unavailable != broken {
+			t.Errorf("goal for game %d: got unavailable %v, want %v", goal.Goal.GameIDs[0], goal.Unavailable, broken)
+		}
+		if !broken && goal.Current != 1 {
+			t.Errorf("goal for game 1: got %d unlocked, want 1", goal.Current)
+		}
+	}
+}
@@ -0,0 +1,608 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+	"golang.org/x/sync/errgroup"
+)
+
+// Group is a set of users sharing a leaderboard.
+type Group struct {
+	ID    string
+	Name  string
+	Owner string
+	// Members lists the Steam IDs of every member, including the owner.
+	Members []string
+	// JoinToken allows users to add themselves to the group through its
+	// invite link.
+	JoinToken string
+	// Goals are challenges set by the owner for every member to compete on.
+	Goals     []Goal
+	CreatedAt time.Time
+	// RenewedAt is when the group was last saved, extending its groupTTL.
+	RenewedAt time.Time
+}
+
+// IsMember reports whether the user belongs to the group.
+func (g Group) IsMember(steamID string) bool {
+	return slices.Contains(g.Members, steamID)
+}
+
+// IsOwner reports whether the user owns the group.
+func (g Group) IsOwner(steamID string) bool {
+	return g.Owner == steamID
+}
+
+const (
+	// groupTTL is how long a group is kept after it was last changed or its
+	// leaderboard was viewed.
+	groupTTL = time.Hour * 24 * 365
+	// groupRenewAfter is how long after a group was last saved that viewing
+	// its leaderboard saves it again, rather than on every view.
+	groupRenewAfter = time.Hour * 24 * 30
+	// maxGroupMembers bounds the size of each group, as every member's stats
+	// are loaded for its leaderboard.
+	maxGroupMembers = 50
+	// maxGroupName bounds the length of group names.
+	maxGroupName = 64
+	// leaderboardConcurrency bounds how many members have their stats loaded
+	// at once.
+	leaderboardConcurrency = 4
+	// sharedGamesLimit is the most games offered for per-game leaderboards.
+	sharedGamesLimit = 25
+)
+
+var (
+	ErrGroupNotFound     = errors.New("group not found")
+	ErrNotGroupOwner     = errors.New("only the group's owner may do this")
+	ErrInvalidJoinToken  = errors.New("the invite link is invalid or has been replaced")
+	ErrGroupFull         = fmt.Errorf("groups may have no more than %d members", maxGroupMembers)
+	ErrGroupOwnerLeaving = errors.New("the group's owner cannot leave it. Delete the group instead")
+)
+
+// CreateGroup creates a group owned by, and containing, the given user.
+func (d *Data) CreateGroup(ctx context.Context, ownerID string, name string) (Group, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxGroupName {
+		return Group{}, fmt.Errorf("group names must be between 1 and %d characters", maxGroupName)
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return Group{}, err
+	}
+	token, err := randomHex(16)
+	if err != nil {
+		return Group{}, err
+	}
+
+	now := time.Now()
+	group := Group{
+		ID:        id,
+		Name:      name,
+		Owner:     ownerID,
+		Members:   []string{ownerID},
+		JoinToken: token,
+		CreatedAt: now,
+		RenewedAt: now,
+	}
+	if err := d.saveGroup(ctx, group); err != nil {
+		return Group{}, err
+	}
+	return group, d.indexGroupMembers(ctx, group.ID, nil, group.Members)
+}
+
+// GetGroup returns the group with the given ID.
+func (d *Data) GetGroup(ctx context.Context, id string) (Group, error) {
+	ret := Group{}
+	err := d.cache.Get(ctx, keyGroup.Key(id), &ret)
+	if errors.Is(err, cache.ErrNotFound) {
+		return Group{}, fmt.Errorf("%w: %q", ErrGroupNotFound, id)
+	} else if err != nil {
+		return Group{}, fmt.Errorf("could not get group %q: %w", id, err)
+	}
+	return ret, nil
+}
+
+// GetUserGroups returns every group that the user is a member of, by name.
+func (d *Data) GetUserGroups(ctx context.Context, steamID string) ([]Group, error) {
+	ids, err := d.userGroupIDs(ctx, steamID)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []Group{}
+	for _, id := range ids {
+		group, err := d.GetGroup(ctx, id)
+		if errors.Is(err, ErrGroupNotFound) {
+			continue
+		} else if err != nil {
+			return ret, err
+		}
+
+		if group.IsMember(steamID) {
+			ret = append(ret, group)
+		}
+	}
+
+	slices.SortFunc(ret, func(a, b Group) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return ret, nil
+}
+
+// userGroupIDs returns the IDs of the groups that the user is a member of,
+// from their index of groups. Users without an index, such as for groups
+// created before indexes were kept, have theirs built by scanning every group.
+func (d *Data) userGroupIDs(ctx context.Context, steamID string) ([]string, error) {
+	ret := []string{}
+	key := keyPlayerGroups.Key(steamID)
+	if err := d.cache.Get(ctx, key, &ret); err == nil {
+		return ret, nil
+	} else if !errors.Is(err, cache.ErrNotFound) {
+		return nil, fmt.Errorf("could not get groups for %q: %w", steamID, err)
+	}
+
+	keys, err := d.cache.Keys(ctx, keyGroup.Pattern())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		group := Group{}
+		if err := d.cache.Get(ctx, key, &group); errors.Is(err, cache.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if group.IsMember(steamID) {
+			ret = append(ret, group.ID)
+		}
+	}
+
+	// Only stored if the index was not written while scanning, to not lose
+	// the change that wrote it
+	if _, err := d.cache.SetNX(ctx, key, ret, groupTTL); err != nil {
+		slog.Warn("Unable to save group index", "steam-id", steamID, "error", err)
+	}
+	return ret, nil
+}
+
+// indexGroupMembers updates the group indexes of the users who joined or left
+// the group, given its members before and after a change.
+func (d *Data) indexGroupMembers(ctx context.Context, id string, before []string, after []string) error {
+	for _, steamID := range after {
+		if !slices.Contains(before, steamID) {
+			if err := d.indexUserGroup(ctx, steamID, id, true); err != nil {
+				return err
+			}
+		}
+	}
+	for _, steamID := range before {
+		if !slices.Contains(after, steamID) {
+			if err := d.indexUserGroup(ctx, steamID, id, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexUserGroup adds the group to, or removes it from, the user's index of
+// groups.
+func (d *Data) indexUserGroup(ctx context.Context, steamID string, id string, member bool) error {
+	key := keyPlayerGroups.Key(steamID)
+	unlock, err := d.lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	ids, err := d.userGroupIDs(ctx, steamID)
+	if err != nil {
+		return err
+	}
+
+	ids = slices.DeleteFunc(ids, func(groupID string) bool { return groupID == id })
+	if member {
+		ids = append(ids, id)
+	}
+
+	if err := d.cache.Set(ctx, key, ids, groupTTL); err != nil {
+		return fmt.Errorf("could not save groups for %q: %w", steamID, err)
+	}
+	return nil
+}
+
+// JoinGroup adds the user to the group, given the token from its invite link.
+func (d *Data) JoinGroup(ctx context.Context, id string, token string, steamID string) (Group, error) {
+	return d.updateGroup(ctx, id, func(group *Group) error {
+		if token == "" || token != group.JoinToken {
+			return ErrInvalidJoinToken
+		}
+		return addMember(group, steamID)
+	})
+}
+
+// AddGroupMember adds a user to the group on behalf of its owner.
+func (d *Data) AddGroupMember(ctx context.Context, id string, actorID string, steamID string) (Group, error) {
+	return d.updateGroup(ctx, id, func(group *Group) error {
+		if !group.IsOwner(actorID) {
+			return ErrNotGroupOwner
+		}
+		return addMember(group, steamID)
+	})
+}
+
+func addMember(group *Group, steamID string) error {
+	if group.IsMember(steamID) {
+		return nil
+	} else if len(group.Members) >= maxGroupMembers {
+		return ErrGroupFull
+	}
+
+	group.Members = append(group.Members, steamID)
+	return nil
+}
+
+// RemoveGroupMember removes a user from the group. Members may remove
+// themselves, and the owner may remove anybody but themselves.
+func (d *Data) RemoveGroupMember(ctx context.Context, id string, actorID string, steamID string) (Group, error) {
+	return d.updateGroup(ctx, id, func(group *Group) error {
+		if actorID != steamID && !group.IsOwner(actorID) {
+			return ErrNotGroupOwner
+		} else if group.IsOwner(steamID) {
+			return ErrGroupOwnerLeaving
+		}
+
+		group.Members = slices.DeleteFunc(group.Members, func(member string) bool { return member == steamID })
+		return nil
+	})
+}
+
+// ResetGroupInvite replaces the group's join token, so that previously shared
+// invite links stop working.
+func (d *Data) ResetGroupInvite(ctx context.Context, id string, actorID string) (Group, error) {
+	token, err := randomHex(16)
+	if err != nil {
+		return Group{}, err
+	}
+
+	return d.updateGroup(ctx, id, func(group *Group) error {
+		if !group.IsOwner(actorID) {
+			return ErrNotGroupOwner
+		}
+		group.JoinToken = token
+		return nil
+	})
+}
+
+// DeleteGroup deletes the group on behalf of its owner.
+func (d *Data) DeleteGroup(ctx context.Context, id string, actorID string) error {
+	unlock, err := d.lock(ctx, keyGroup.Key(id))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	group, err := d.GetGroup(ctx, id)
+	if err != nil {
+		return err
+	} else if !group.IsOwner(actorID) {
+		return ErrNotGroupOwner
+	}
+
+	if err := d.cache.Delete(ctx, keyGroup.Key(id)); err != nil {
+		return err
+	}
+	return d.indexGroupMembers(ctx, id, group.Members, nil)
+}
+
+// updateGroup applies a change to the group and saves it, unless the change
+// returns an error. Changes are serialized across every replica.
+func (d *Data) updateGroup(ctx context.Context, id string, change func(*Group) error) (Group, error) {
+	unlock, err := d.lock(ctx, keyGroup.Key(id))
+	if err != nil {
+		return Group{}, err
+	}
+	defer unlock()
+
+	group, err := d.GetGroup(ctx, id)
+	if err != nil {
+		return Group{}, err
+	}
+
+	members := slices.Clone(group.Members)
+	if err := change(&group); err != nil {
+		return Group{}, err
+	}
+	group.RenewedAt = time.Now()
+
+	if err := d.saveGroup(ctx, group); err != nil {
+		return Group{}, err
+	}
+	return group, d.indexGroupMembers(ctx, id, members, group.Members)
+}
+
+func (d *Data) saveGroup(ctx context.Context, group Group) error {
+	if err := d.cache.Set(ctx, keyGroup.Key(group.ID), group, groupTTL); err != nil {
+		return fmt.Errorf("could not save group %q: %w", group.ID, err)
+	}
+	return nil
+}
+
+// LeaderboardSort is the statistic that a leaderboard ranks members by.
+type LeaderboardSort string
+
+const (
+	SortUnlocks LeaderboardSort = "unlocks"
+	SortPerfect LeaderboardSort = "perfect"
+	SortScore   LeaderboardSort = "score"
+	SortRecent  LeaderboardSort = "recent"
+)
+
+// LeaderboardSorts lists every statistic a leaderboard may be ranked by.
+var LeaderboardSorts = []LeaderboardSort{SortUnlocks, SortPerfect, SortScore, SortRecent}
+
+// Label is the human readable name of the statistic.
+func (s LeaderboardSort) Label() string {
+	switch s {
+	case SortPerfect:
+		return "Perfect Games"
+	case SortScore:
+		return "Score"
+	case SortRecent:
+		return "Last 30 Days"
+	default:
+		return "Unlocks"
+	}
+}
+
+// Leaderboard ranks a group's members by their account-wide statistics.
+type Leaderboard struct {
+	Group   Group
+	Sort    LeaderboardSort
+	Entries []LeaderboardEntry
+	// Games lists the games played by more than one member, most shared
+	// first, for which per-game leaderboards are available.
+	Games []SharedGame
+}
+
+type LeaderboardEntry struct {
+	Rank          int
+	User          User
+	TotalUnlocked int
+	PerfectGames  int
+	Points        int
+	RecentUnlocks int
+	// Unavailable is set when the member's stats could not be loaded, such
+	// as when their profile is private. They are ranked last.
+	Unavailable bool
+}
+
+// Value is the statistic the entry is ranked by.
+func (e LeaderboardEntry) Value(sort LeaderboardSort) int {
+	switch sort {
+	case SortPerfect:
+		return e.PerfectGames
+	case SortScore:
+		return e.Points
+	case SortRecent:
+		return e.RecentUnlocks
+	default:
+		return e.TotalUnlocked
+	}
+}
+
+type SharedGame struct {
+	Game    Game
+	Members int
+}
+
+// GetLeaderboard ranks the group's members by the given statistic.
+func (d *Data) GetLeaderboard(ctx context.Context, group Group, by LeaderboardSort) (Leaderboard, error) {
+	if !slices.Contains(LeaderboardSorts, by) {
+		by = SortUnlocks
+	}
+
+	// Groups are kept for as long as they are in use
+	if time.Since(group.RenewedAt) > groupRenewAfter {
+		if _, err := d.updateGroup(ctx, group.ID, func(*Group) error { return nil }); err != nil {
+			slog.Warn("Unable to renew group", "group-id", group.ID, "error", err)
+		}
+	}
+
+	ret := Leaderboard{Group: group, Sort: by, Entries: []LeaderboardEntry{}, Games: []SharedGame{}}
+	shared := map[uint64]*SharedGame{}
+	mx := sync.Mutex{}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(leaderboardConcurrency)
+	for _, member := range group.Members {
+		g.Go(func() error {
+			entry := LeaderboardEntry{User: User{SteamID: member, Name: member}}
+			log := slog.With("group-id", group.ID, "steam-id", member)
+
+			user, err := d.GetUser(gctx, member)
+			if err != nil {
+				log.Warn("Unable to load group member", "error", err)
+			} else {
+				entry.User = user
+			}
+
+			stats, err := d.GetUserStats(gctx, member)
+			if err != nil {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+				log.Warn("Unable to load group member stats", "error", err)
+				entry.Unavailable = true
+			} else {
+				entry.TotalUnlocked = stats.TotalUnlocked
+				entry.PerfectGames = stats.PerfectGames
+				entry.Points = stats.Points
+				entry.RecentUnlocks = stats.RecentUnlocks
+			}
+
+			games, err := d.GetGames(gctx, member)
+			if err != nil {
+				log.Warn("Unable to load group member games", "error", err)
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+			ret.Entries = append(ret.Entries, entry)
+			for _, game := range games {
+				if _, ok := shared[game.ID]; !ok {
+					shared[game.ID] = &SharedGame{Game: Game{ID: game.ID, DisplayName: game.DisplayName, Icon: game.Icon}}
+				}
+				shared[game.ID].Members++
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return Leaderboard{}, fmt.Errorf("could not build leaderboard for %q: %w", group.ID, err)
+	}
+
+	sort.Slice(ret.Entries, func(i, j int) bool {
+		a, b := ret.Entries[i], ret.Entries[j]
+		if a.Unavailable != b.Unavailable {
+			return b.Unavailable
+		} else if a.Value(by) != b.Value(by) {
+			return a.Value(by) > b.Value(by)
+		}
+		return strings.ToLower(a.User.Name) < strings.ToLower(b.User.Name)
+	})
+
+	// Members with the same value share a rank
+	for i := range ret.Entries {
+		entry := &ret.Entries[i]
+		switch {
+		case entry.Unavailable:
+		case i > 0 && entry.Value(by) == ret.Entries[i-1].Value(by):
+			entry.Rank = ret.Entries[i-1].Rank
+		default:
+			entry.Rank = i + 1
+		}
+	}
+
+	for _, game := range shared {
+		if game.Members > 1 {
+			ret.Games = append(ret.Games, *game)
+		}
+	}
+	sort.Slice(ret.Games, func(i, j int) bool {
+		if ret.Games[i].Members != ret.Games[j].Members {
+			return ret.Games[i].Members > ret.Games[j].Members
+		}
+		return strings.ToLower(ret.Games[i].Game.DisplayName) < strings.ToLower(ret.Games[j].Game.DisplayName)
+	})
+	ret.Games = ret.Games[:min(len(ret.Games), sharedGamesLimit)]
+
+	return ret, nil
+}
+
+// GameLeaderboard ranks a group's members by their progress in a single game.
+type GameLeaderboard struct {
+	Group   Group
+	Game    Game
+	Entries []GameLeaderboardEntry
+}
+
+type GameLeaderboardEntry struct {
+	Rank       int
+	User       User
+	Unlocked   int
+	Total      int
+	Percentage int
+	Points     int
+	// LastUnlock orders members with as many unlocks, who share a rank, in
+	// favor of whoever got there first.
+	LastUnlock time.Time
+}
+
+// GetGameLeaderboard ranks the group's members by the achievements they have
+// unlocked in the game. Members that have not played it are left out.
+func (d *Data) GetGameLeaderboard(ctx context.Context, group Group, gameID uint64) (GameLeaderboard, error) {
+	ret := GameLeaderboard{Group: group, Game: Game{ID: gameID}, Entries: []GameLeaderboardEntry{}}
+	mx := sync.Mutex{}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(leaderboardConcurrency)
+	for _, member := range group.Members {
+		g.Go(func() error {
+			log := slog.With("group-id", group.ID, "steam-id", member, "game-id", gameID)
+
+			game, err := d.GetGame(gctx, member, gameID)
+			if err != nil || game.ID == 0 {
+				return nil
+			}
+
+			achievements, err := d.GetAchievements(gctx, member, gameID)
+			if err != nil {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+				log.Warn("Unable to load group member achievements", "error", err)
+				return nil
+			}
+
+			user, err := d.GetUser(gctx, member)
+			if err != nil {
+				log.Warn("Unable to load group member", "error", err)
+				user = User{SteamID: member, Name: member}
+			}
+
+			progress := achievements.Progress()
+			mx.Lock()
+			defer mx.Unlock()
+			ret.Game = Game{ID: game.ID, DisplayName: game.DisplayName, Icon: game.Icon}
+			ret.Entries = append(ret.Entries, GameLeaderboardEntry{
+				User:       user,
+				Unlocked:   progress.Unlocked,
+				Total:      progress.Total,
+				Percentage: progress.Percentage,
+				Points:     achievements.Points,
+				LastUnlock: progress.LastUnlock,
+			})
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return GameLeaderboard{}, fmt.Errorf("could not build leaderboard for %q: %w", group.ID, err)
+	}
+
+	sort.Slice(ret.Entries, func(i, j int) bool {
+		a, b := ret.Entries[i], ret.Entries[j]
+		if a.Unlocked != b.Unlocked {
+			return a.Unlocked > b.Unlocked
+		} else if !a.LastUnlock.Equal(b.LastUnlock) && a.Unlocked > 0 {
+			return a.LastUnlock.Before(b.LastUnlock)
+		}
+		return strings.ToLower(a.User.Name) < strings.ToLower(b.User.Name)
+	})
+
+	for i := range ret.Entries {
+		entry := &ret.Entries[i]
+		if i > 0 && entry.Unlocked == ret.Entries[i-1].Unlocked {
+			entry.Rank = ret.Entries[i-1].Rank
+		} else {
+			entry.Rank = i + 1
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,155 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taiidani/achievements/internal/steam"
+)
+
+// TestJoinGroupAcrossReplicas guards against concurrent changes made through
+// separate replicas overwriting each other.
+func TestJoinGroupAcrossReplicas(t *testing.T) {
+	d := newTestData(t, newFakeSteam())
+	replica := NewData(steam.NewClient(), d.cache, nil)
+	ctx := context.Background()
+
+	group, err := d.CreateGroup(ctx, "owner", "Group")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			backend := d
+			if i%2 == 0 {
+				backend = replica
+			}
+			if _, err := backend.JoinGroup(ctx, group.ID, group.JoinToken, fmt.Sprintf("member-%d", i)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	group, err = d.GetGroup(ctx, group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(group.Members) != 21 {
+		t.Errorf("got %d members, want 21", len(group.Members))
+	}
+	for i := range 20 {
+		groups, err := replica.GetUserGroups(ctx, fmt.Sprintf("member-%d", i))
+		if err != nil {
+			t.Fatal(err)
+		} else if len(groups) != 1 {
+			t.Errorf("member %d: got %d groups, want 1", i, len(groups))
+		}
+	}
+}
+
+func TestUserGroupIndex(t *testing.T) {
+	d := newTestData(t, newFakeSteam())
+	ctx := context.Background()
+
+	// Groups saved before indexes were kept are found by scanning once
+	legacy := Group{ID: "legacy", Name: "Legacy", Owner: "owner", Members: []string{"owner", "member"}}
+	if err := d.saveGroup(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	created, err := d.CreateGroup(ctx, "owner", "Created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.AddGroupMember(ctx, created.ID, "owner", "member"); err != nil {
+		t.Fatal(err)
+	}
+
+	groupIDs := func(steamID string) []string {
+		t.Helper()
+		groups, err := d.GetUserGroups(ctx, steamID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret := []string{}
+		for _, group := range groups {
+			ret = append(ret, group.ID)
+		}
+		return ret
+	}
+
+	want := []string{created.ID, "legacy"}
+	if got := groupIDs("member"); !slices.Equal(got, want) {
+		t.Errorf("got groups %v, want %v", got, want)
+	}
+
+	// Once indexed, groups are no longer scanned for
+	if err := d.cache.Delete(ctx, keyGroup.Key("legacy")); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.saveGroup(ctx, Group{ID: "unindexed", Name: "Unindexed", Owner: "owner", Members: []string{"member"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := groupIDs("member"); !slices.Equal(got, []string{created.ID}) {
+		t.Errorf("got groups %v, want only %q", got, created.ID)
+	}
+
+	if _, err := d.RemoveGroupMember(ctx, created.ID, "member", "member"); err != nil {
+		t.Fatal(err)
+	}
+	if got := groupIDs("member"); len(got) != 0 {
+		t.Errorf("got groups %v after leaving, want none", got)
+	}
+
+	if err := d.DeleteGroup(ctx, created.ID, "owner"); err != nil {
+		t.Fatal(err)
+	}
+	if got := groupIDs("owner"); len(got) != 0 {
+		t.Errorf("got groups %v after deleting, want none", got)
+	}
+}
+
+func TestGetLeaderboardRenewsGroup(t *testing.T) {
+	f := newFakeSteam()
+	f.users["owner"] = []fakeGame{{ID: 1, Name: "Game", Achievements: 2, LastPlayed: time.Now()}}
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	group, err := d.CreateGroup(ctx, "owner", "Group")
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewedAt := group.RenewedAt
+
+	// Recently saved groups are not saved again
+	if _, err := d.GetLeaderboard(ctx, group, SortUnlocks); err != nil {
+		t.Fatal(err)
+	}
+	if group, err = d.GetGroup(ctx, group.ID); err != nil {
+		t.Fatal(err)
+	} else if !group.RenewedAt.Equal(renewedAt) {
+		t.Errorf("got group renewed at %s, want %s", group.RenewedAt, renewedAt)
+	}
+
+	// Groups nearing expiry are
+	group.RenewedAt = time.Now().Add(-groupRenewAfter - time.Hour)
+	if err := d.saveGroup(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetLeaderboard(ctx, group, SortUnlocks); err != nil {
+		t.Fatal(err)
+	}
+	if group, err = d.GetGroup(ctx, group.ID); err != nil {
+		t.Fatal(err)
+	} else if time.Since(group.RenewedAt) > time.Minute {
+		t.Errorf("got group renewed at %s, want it renewed now", group.RenewedAt)
+	}
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/history"
+	"github.com/taiidani/achievements/internal/steam"
+)
+
+// recordHistory snapshots the player's unlocked achievements, storing an event
+// for any that are new and publishing them as AchievementsUnlocked. Failures
+// are logged rather than returned, as history is secondary to displaying the
+// achievements themselves.
+func (d *Data) recordHistory(ctx context.Context, userID string, gameID uint64, achievements *steam.PlayerAchievements) []history.Event {
+	if d.history == nil {
+		return nil
+	}
+
+	unlocked := map[string]time.Time{}
+	for _, achievement := range achievements.PlayerStats.Achievements {
+		if achievement.Achieved == 0 {
+			continue
+		}
+
+		unlocked[achievement.APIName] = time.Time{}
+		if achievement.UnlockTime > 0 {
+			unlocked[achievement.APIName] = time.Unix(int64(achievement.UnlockTime), 0)
+		}
+	}
+
+	events, err := d.history.Record(ctx, userID, gameID, unlocked)
+	if err != nil {
+		slog.Warn("Unable to record achievement history", "steam-id", userID, "game-id", gameID, "error", err)
+		return nil
+	}
+
+	if len(events) > 0 {
+		slog.Debug("Recorded new achievement unlocks", "steam-id", userID, "game-id", gameID, "count", len(events))
+		d.events.Publish(ctx, AchievementsUnlocked{
+			EventBase: EventBase{SteamID: userID, At: time.Now()},
+			GameID:    gameID,
+			Unlocks:   events,
+		})
+	}
+	return events
+}
+
+// RecordsHistory reports whether unlock history is recorded. New unlocks are
+// only detected, and so only published as AchievementsUnlocked, when it is.
+func (d *Data) RecordsHistory() bool {
+	return d.history != nil
+}
+
+// GetUnlockHistory returns the achievements the user unlocked within [from,
+// to), newest first, as recorded by the history store. This includes unlocks
+// that Steam does not report a time for, dated by when they were first seen.
+func (d *Data) GetUnlockHistory(ctx context.Context, userID string, from, to time.Time) ([]history.Event, error) {
+	if d.history == nil {
+		return []history.Event{}, nil
+	}
+
+	events, err := d.history.Events(ctx, userID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("could not query unlock history for %q: %w", userID, err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,77 @@
+// Package history keeps a durable record of the achievements each user has
+// unlocked, independently of the expiring Steam data held in the cache.
+//
+// Every time a user's achievements for a game are fetched, the set of unlocked
+// achievements is compared against the previous snapshot for that game. Each
+// newly unlocked achievement is stored as an Event, timestamped by Steam where
+// it reports an unlock time and by the time it was first observed otherwise.
+package history
+
+import (
+	"context"
+	"time"
+)
+
+// Store records achievement snapshots and the unlock events derived from them.
+type Store interface {
+	// Record compares the achievements currently unlocked in a game against
+	// the previous snapshot, storing and returning an Event for each new
+	// unlock. The map is keyed by achievement API name, with the unlock time
+	// reported by Steam as the value, or the zero time if Steam reported none.
+	Record(ctx context.Context, steamID string, appID uint64, unlocked map[string]time.Time) ([]Event, error)
+	// Events returns the user's unlock events with an UnlockedAt within
+	// [from, to), newest first.
+	Events(ctx context.Context, steamID string, from, to time.Time) ([]Event, error)
+	Close() error
+}
+
+// Event records a single achievement unlock.
+type Event struct {
+	SteamID string
+	AppID   uint64
+	APIName string
+	// UnlockedAt is the unlock time reported by Steam or, when Steam does not
+	// report one, the time the unlock was first observed.
+	UnlockedAt time.Time
+	// DetectedAt is the time the unlock was first observed.
+	DetectedAt time.Time
+}
+
+// Estimated reports whether UnlockedAt is the time the unlock was observed,
+// rather than the time reported by Steam.
+func (e Event) Estimated() bool {
+	return e.UnlockedAt.Equal(e.DetectedAt)
+}
+
+// diff builds the events for achievements unlocked in current but not in
+// previous.
+//
+// On the first snapshot of a game there is nothing to compare against, so
+// events are only created for unlocks that Steam reports a time for. Unlocks
+// without one are recorded in the snapshot alone, as there is no way of
+// knowing when they happened.
+func diff(steamID string, appID uint64, previous map[string]time.Time, first bool, current map[string]time.Time, now time.Time) []Event {
+	ret := []Event{}
+	for name, unlockedAt := range current {
+		if _, ok := previous[name]; ok {
+			continue
+		}
+
+		if unlockedAt.IsZero() {
+			if first {
+				continue
+			}
+			unlockedAt = now
+		}
+
+		ret = append(ret, Event{
+			SteamID:    steamID,
+			AppID:      appID,
+			APIName:    name,
+			UnlockedAt: unlockedAt,
+			DetectedAt: now,
+		})
+	}
+
+	return ret
+}
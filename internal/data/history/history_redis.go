@@ -0,0 +1,182 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+	"github.com/taiidani/achievements/internal/data/cache"
+)
+
+// Redis stores history in Redis, without any expiration.
+type Redis struct {
+	client redis.UniversalClient
+}
+
+var _ Store = &Redis{}
+
+// Every key for a user shares the "{<steam-id>}" hash tag, placing them in the
+// same slot of a Redis Cluster so that they may be written in one transaction.
+var (
+	// keySnapshot is a hash of achievement API name to unlock time.
+	keySnapshot = cache.KeyFamily{Format: "history:{%s}:game:%d:snapshot", Version: 1}
+	// keySnapshotTaken records when the snapshot was last changed, and that a
+	// snapshot exists at all as Redis drops empty hashes.
+	keySnapshotTaken = cache.KeyFamily{Format: "history:{%s}:game:%d:taken", Version: 1}
+	// keyEvents is a sorted set of "<app-id>:<api-name>" members, scored by
+	// unlock time.
+	keyEvents = cache.KeyFamily{Format: "history:{%s}:events", Version: 1}
+	// keyEventsDetected is a hash of keyEvents members to detection time.
+	keyEventsDetected = cache.KeyFamily{Format: "history:{%s}:events:detected", Version: 1}
+)
+
+// KeyFamilies lists every cache key family written by the Redis store. Unlike
+// the cache's own keys they are the only record of a user's history.
+func KeyFamilies() []cache.KeyFamily {
+	return []cache.KeyFamily{keySnapshot, keySnapshotTaken, keyEvents, keyEventsDetected}
+}
+
+func NewRedis(client redis.UniversalClient) *Redis {
+	return &Redis{client: client}
+}
+
+// recordAttempts bounds how many times Record retries when the snapshot is
+// changed by a concurrent recording while it is being compared.
+const recordAttempts = 5
+
+// Record compares against the snapshot within a WATCH transaction, so that
+// concurrent recordings of the same game cannot both report an unlock.
+func (s *Redis) Record(ctx context.Context, steamID string, appID uint64, unlocked map[string]time.Time) ([]Event, error) {
+	snapshotKey := keySnapshot.Key(steamID, appID)
+	takenKey := keySnapshotTaken.Key(steamID, appID)
+
+	var ret []Event
+	record := func(tx *redis.Tx) error {
+		var err error
+		ret, err = s.record(ctx, tx, steamID, appID, unlocked)
+		return err
+	}
+
+	for range recordAttempts {
+		err := s.client.Watch(ctx, record, takenKey, snapshotKey)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		return ret, err
+	}
+
+	return nil, fmt.Errorf("unable to store snapshot: changed by %d concurrent recordings", recordAttempts)
+}
+
+// record stores the snapshot through the watching transaction, returning the
+// events that were added by it.
+func (s *Redis) record(ctx context.Context, tx *redis.Tx, steamID string, appID uint64, unlocked map[string]time.Time) ([]Event, error) {
+	snapshotKey := keySnapshot.Key(steamID, appID)
+	takenKey := keySnapshotTaken.Key(steamID, appID)
+
+	first := false
+	if err := tx.Get(ctx, takenKey).Err(); err == redis.Nil {
+		first = true
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to query snapshot: %w", err)
+	}
+
+	stored, err := tx.HGetAll(ctx, snapshotKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to query snapshot achievements: %w", err)
+	}
+	previous := map[string]time.Time{}
+	for name, unlockedAt := range stored {
+		sec, _ := strconv.ParseInt(unlockedAt, 10, 64)
+		previous[name] = unixTime(sec)
+	}
+
+	now := time.Now()
+	events := diff(steamID, appID, previous, first, unlocked, now)
+	if !first && len(events) == 0 && len(previous) == len(unlocked) {
+		// Nothing has changed since the last snapshot
+		return events, nil
+	}
+
+	eventsKey := keyEvents.Key(steamID)
+	detectedKey := keyEventsDetected.Key(steamID)
+	added := make([]*redis.IntCmd, len(events))
+	_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, takenKey, now.Unix(), 0)
+		pipe.Del(ctx, snapshotKey)
+		for name, unlockedAt := range unlocked {
+			pipe.HSet(ctx, snapshotKey, name, unixSeconds(unlockedAt))
+		}
+
+		// An unlock dropped from a snapshot and reported again may already
+		// have an event, in which case the first one to be written wins
+		for i, event := range events {
+			member := fmt.Sprintf("%d:%s", event.AppID, event.APIName)
+			added[i] = pipe.ZAddNX(ctx, eventsKey, redis.Z{Score: float64(event.UnlockedAt.Unix()), Member: member})
+			pipe.HSetNX(ctx, detectedKey, member, event.DetectedAt.Unix())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to store snapshot: %w", err)
+	}
+
+	ret := []Event{}
+	for i, event := range events {
+		if added[i].Val() > 0 {
+			ret = append(ret, event)
+		}
+	}
+	return ret, nil
+}
+
+func (s *Redis) Events(ctx context.Context, steamID string, from, to time.Time) ([]Event, error) {
+	members, err := s.client.ZRevRangeByScoreWithScores(ctx, keyEvents.Key(steamID), &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.Unix(), 10),
+		Max: "(" + strconv.FormatInt(to.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to query events: %w", err)
+	}
+
+	ret := []Event{}
+	if len(members) == 0 {
+		return ret, nil
+	}
+
+	fields := make([]string, 0, len(members))
+	for _, member := range members {
+		fields = append(fields, member.Member.(string))
+	}
+	detected, err := s.client.HMGet(ctx, keyEventsDetected.Key(steamID), fields...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("unable to query event detection times: %w", err)
+	}
+
+	for i, member := range members {
+		appID, name, _ := strings.Cut(fields[i], ":")
+		event := Event{
+			SteamID:    steamID,
+			APIName:    name,
+			UnlockedAt: time.Unix(int64(member.Score), 0),
+		}
+		event.AppID, _ = strconv.ParseUint(appID, 10, 64)
+		if sec, ok := detected[i].(string); ok {
+			parsed, _ := strconv.ParseInt(sec, 10, 64)
+			event.DetectedAt = unixTime(parsed)
+		}
+
+		ret = append(ret, event)
+	}
+
+	return ret, nil
+}
+
+// Close is a no-op, as the client is owned by the cache.
+func (s *Redis) Close() error {
+	return nil
+}
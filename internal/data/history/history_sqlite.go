@@ -0,0 +1,181 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLite stores history in a local SQLite database file.
+type SQLite struct {
+	db *sql.DB
+}
+
+var _ Store = &SQLite{}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS snapshots (
+	steam_id TEXT NOT NULL,
+	app_id INTEGER NOT NULL,
+	taken_at INTEGER NOT NULL,
+	PRIMARY KEY (steam_id, app_id)
+);
+
+CREATE TABLE IF NOT EXISTS snapshot_achievements (
+	steam_id TEXT NOT NULL,
+	app_id INTEGER NOT NULL,
+	api_name TEXT NOT NULL,
+	unlocked_at INTEGER NOT NULL,
+	PRIMARY KEY (steam_id, app_id, api_name)
+);
+
+CREATE TABLE IF NOT EXISTS events (
+	steam_id TEXT NOT NULL,
+	app_id INTEGER NOT NULL,
+	api_name TEXT NOT NULL,
+	unlocked_at INTEGER NOT NULL,
+	detected_at INTEGER NOT NULL,
+	PRIMARY KEY (steam_id, app_id, api_name)
+);
+
+CREATE INDEX IF NOT EXISTS events_by_unlock ON events (steam_id, unlocked_at);
+`
+
+// NewSQLite opens the database at path, creating it if necessary.
+func NewSQLite(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %q: %w", path, err)
+	}
+
+	// SQLite only supports a single writer at a time
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("unable to create schema: %w", err)
+	}
+
+	return &SQLite{db: db}, nil
+}
+
+func (s *SQLite) Record(ctx context.Context, steamID string, appID uint64, unlocked map[string]time.Time) ([]Event, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var takenAt int64
+	err = tx.QueryRowContext(ctx, `SELECT taken_at FROM snapshots WHERE steam_id = ? AND app_id = ?`, steamID, appID).Scan(&takenAt)
+	first := err == sql.ErrNoRows
+	if err != nil && !first {
+		return nil, fmt.Errorf("unable to query snapshot: %w", err)
+	}
+
+	previous := map[string]time.Time{}
+	rows, err := tx.QueryContext(ctx, `SELECT api_name, unlocked_at FROM snapshot_achievements WHERE steam_id = ? AND app_id = ?`, steamID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query snapshot achievements: %w", err)
+	}
+	for rows.Next() {
+		var name string
+		var unlockedAt int64
+		if err := rows.Scan(&name, &unlockedAt); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		previous[name] = unixTime(unlockedAt)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	events := diff(steamID, appID, previous, first, unlocked, now)
+	if !first && len(events) == 0 && len(previous) == len(unlocked) {
+		// Nothing has changed since the last snapshot
+		return events, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO snapshots (steam_id, app_id, taken_at) VALUES (?, ?, ?)
+		ON CONFLICT (steam_id, app_id) DO UPDATE SET taken_at = excluded.taken_at`, steamID, appID, now.Unix()); err != nil {
+		return nil, fmt.Errorf("unable to store snapshot: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM snapshot_achievements WHERE steam_id = ? AND app_id = ?`, steamID, appID); err != nil {
+		return nil, fmt.Errorf("unable to clear snapshot achievements: %w", err)
+	}
+	for name, unlockedAt := range unlocked {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO snapshot_achievements (steam_id, app_id, api_name, unlocked_at) VALUES (?, ?, ?, ?)`,
+			steamID, appID, name, unixSeconds(unlockedAt)); err != nil {
+			return nil, fmt.Errorf("unable to store snapshot achievement: %w", err)
+		}
+	}
+
+	// An unlock dropped from a snapshot and reported again may already have an
+	// event, which is not returned again
+	added := []Event{}
+	for _, event := range events {
+		res, err := tx.ExecContext(ctx, `INSERT INTO events (steam_id, app_id, api_name, unlocked_at, detected_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING`, event.SteamID, event.AppID, event.APIName, event.UnlockedAt.Unix(), event.DetectedAt.Unix())
+		if err != nil {
+			return nil, fmt.Errorf("unable to store event: %w", err)
+		}
+
+		if inserted, err := res.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("unable to store event: %w", err)
+		} else if inserted > 0 {
+			added = append(added, event)
+		}
+	}
+
+	return added, tx.Commit()
+}
+
+func (s *SQLite) Events(ctx context.Context, steamID string, from, to time.Time) ([]Event, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT app_id, api_name, unlocked_at, detected_at FROM events
+		WHERE steam_id = ? AND unlocked_at >= ? AND unlocked_at < ?
+		ORDER BY unlocked_at DESC`, steamID, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("unable to query events: %w", err)
+	}
+	defer rows.Close()
+
+	ret := []Event{}
+	for rows.Next() {
+		event := Event{SteamID: steamID}
+		var unlockedAt, detectedAt int64
+		if err := rows.Scan(&event.AppID, &event.APIName, &unlockedAt, &detectedAt); err != nil {
+			return ret, err
+		}
+
+		event.UnlockedAt = unixTime(unlockedAt)
+		event.DetectedAt = unixTime(detectedAt)
+		ret = append(ret, event)
+	}
+
+	return ret, rows.Err()
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+// unixSeconds stores the zero time as 0, rather than its negative Unix value.
+func unixSeconds(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,50 @@
+package history
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteRecord(t *testing.T) {
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+
+	ctx := context.Background()
+	unlockedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	unlocked := map[string]time.Time{"A": unlockedAt, "B": unlockedAt}
+
+	tests := []struct {
+		name     string
+		unlocked map[string]time.Time
+		want     int
+	}{
+		{name: "first snapshot", unlocked: unlocked, want: 2},
+		{name: "same unlocks", unlocked: unlocked, want: 0},
+		{name: "unlocks dropped", unlocked: map[string]time.Time{"A": unlockedAt}, want: 0},
+		{name: "unlocks reported again", unlocked: unlocked, want: 0},
+		{name: "new unlock", unlocked: map[string]time.Time{"A": unlockedAt, "B": unlockedAt, "C": {}}, want: 1},
+	}
+
+	for _, tt := range tests {
+		events, err := s.Record(ctx, "1", 620, tt.unlocked)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(events) != tt.want {
+			t.Errorf("%s: got %d events, want %d", tt.name, len(events), tt.want)
+		}
+	}
+
+	events, err := s.Events(ctx, "1", time.Time{}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Errorf("got %d stored events, want 3", len(events))
+	}
+}
@@ -0,0 +1,54 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/history"
+)
+
+// countingHistory records how many times each game was recorded.
+type countingHistory struct {
+	mx      sync.Mutex
+	records map[uint64]int
+}
+
+func (h *countingHistory) Record(_ context.Context, _ string, appID uint64, _ map[string]time.Time) ([]history.Event, error) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.records[appID]++
+	return nil, nil
+}
+
+func (h *countingHistory) Events(context.Context, string, time.Time, time.Time) ([]history.Event, error) {
+	return nil, nil
+}
+
+func (h *countingHistory) Close() error { return nil }
+
+func TestHistoryRecordedOnlyWhenFetched(t *testing.T) {
+	f := newFakeSteam()
+	f.users["1"] = []fakeGame{{ID: 1, Name: "Game", Achievements: 2, Unlocked: 1, UnlockedAt: time.Now()}}
+	d := newTestData(t, f)
+	h := &countingHistory{records: map[uint64]int{}}
+	d.history = h
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := d.GetAchievements(ctx, "1", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := h.records[1]; got != 1 {
+		t.Errorf("got %d recordings from cached achievements, want 1", got)
+	}
+
+	if _, err := d.steam.RefreshPlayerAchievements(ctx, "1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.records[1]; got != 2 {
+		t.Errorf("got %d recordings after a refresh, want 2", got)
+	}
+}
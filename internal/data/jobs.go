@@ -0,0 +1,452 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+)
+
+// JobStatus is the stage of a job's lifecycle.
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobRetrying  JobStatus = "retrying"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
+
+// Job is a unit of background work, persisted in the cache so that its status
+// can be looked up from any replica.
+type Job struct {
+	ID   string
+	Kind string
+	Args map[string]string
+	// Status is updated as the job runs. Done and Total report its progress
+	// once running, in units of the job's choosing.
+	Status JobStatus
+	Done   int
+	Total  int
+	// Error is the message of the most recent failure, if any.
+	Error       string `json:",omitempty"`
+	Attempts    int
+	MaxAttempts int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	// RetryAt is when a retrying job will be queued again.
+	RetryAt time.Time
+}
+
+// Finished reports whether the job will not run again.
+func (j Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed
+}
+
+// Percentage is how far through its work the job is.
+func (j Job) Percentage() int {
+	if j.Status == JobSucceeded {
+		return 100
+	} else if j.Total == 0 {
+		return 0
+	}
+	return j.Done * 100 / j.Total
+}
+
+// JobHandler performs a job of a single kind, calling progress as it goes.
+// Returning an error retries the job until it runs out of attempts.
+type JobHandler func(ctx context.Context, job Job, progress func(done, total int)) error
+
+// ErrUnknownJob is returned when enqueueing a job of a kind without a handler.
+var ErrUnknownJob = errors.New("unknown job kind")
+
+const (
+	// jobAttempts is how many times a job is run before it is failed.
+	jobAttempts = 3
+	// jobBackoff is the delay before a job's first retry, doubling with each
+	// attempt.
+	jobBackoff = time.Second * 10
+	// jobTTL is how long a job's status is kept after it was last updated.
+	jobTTL = time.Hour * 24 * 7
+	// jobHeartbeat is how often a running job's status is saved, so that
+	// abandoned jobs can be told apart from slow ones.
+	jobHeartbeat = time.Second * 15
+	// jobStaleAfter is how long a job can go without being updated before it
+	// is considered abandoned, such as by a replica that was stopped, and
+	// queued again. It is also how long a worker's lease on a job lasts
+	// without a heartbeat.
+	jobStaleAfter = time.Minute * 2
+	// jobPollTimeout bounds how long a worker waits for a job at a time.
+	jobPollTimeout = time.Second * 5
+)
+
+// Jobs runs background work through a queue shared by every replica. Job
+// statuses are stored in the cache, and jobs abandoned part way through are
+// recovered and run again. A worker leases each job it runs, so that a job
+// queued more than once is only run by one worker at a time.
+type Jobs struct {
+	cache    cache.Cache
+	queue    cache.Queue
+	handlers map[string]JobHandler
+	mx       sync.RWMutex
+}
+
+func NewJobs(c cache.Cache, queue cache.Queue) *Jobs {
+	return &Jobs{
+		cache:    c,
+		queue:    queue,
+		handlers: map[string]JobHandler{},
+	}
+}
+
+// Handle registers the handler for a kind of job. It must be called before
+// Run.
+func (j *Jobs) Handle(kind string, handler JobHandler) {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	j.handlers[kind] = handler
+}
+
+// Enqueue queues a job of the given kind, returning it for its ID.
+func (j *Jobs) Enqueue(ctx context.Context, kind string, args map[string]string) (Job, error) {
+	j.mx.RLock()
+	_, ok := j.handlers[kind]
+	j.mx.RUnlock()
+	if !ok {
+		return Job{}, fmt.Errorf("%w: %q", ErrUnknownJob, kind)
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return Job{}, err
+	}
+
+	now := time.Now()
+	job := Job{
+		ID:          id,
+		Kind:        kind,
+		Args:        args,
+		Status:      JobQueued,
+		MaxAttempts: jobAttempts,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := j.save(ctx, &job); err != nil {
+		return Job{}, fmt.Errorf("could not save job: %w", err)
+	}
+	if err := j.queue.Push(ctx, keyJobQueue.Key(), job.ID); err != nil {
+		return Job{}, fmt.Errorf("could not queue job: %w", err)
+	}
+
+	return job, nil
+}
+
+// Get returns the job with the given ID.
+func (j *Jobs) Get(ctx context.Context, id string) (Job, error) {
+	ret := Job{}
+	if err := j.cache.Get(ctx, keyJob.Key(id), &ret); err != nil {
+		return Job{}, fmt.Errorf("could not get job %q: %w", id, err)
+	}
+	return ret, nil
+}
+
+// Run processes jobs with the given number of workers until the context is
+// cancelled.
+func (j *Jobs) Run(ctx context.Context, workers int) {
+	wg := sync.WaitGroup{}
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.work(ctx)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		j.recover(ctx)
+	}()
+
+	wg.Wait()
+	slog.Info("Job workers exited")
+}
+
+func (j *Jobs) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		id, err := j.queue.Pop(ctx, keyJobQueue.Key(), jobPollTimeout)
+		if errors.Is(err, cache.ErrNotFound) || ctx.Err() != nil {
+			continue
+		} else if errors.Is(err, cache.ErrUnavailable) {
+			// Already reported by the cache, until it recovers
+			time.Sleep(jobPollTimeout)
+			continue
+		} else if err != nil {
+			slog.Warn("Unable to poll for jobs", "error", err)
+			time.Sleep(jobPollTimeout)
+			continue
+		}
+
+		job, ok := j.claim(ctx, id)
+		if !ok {
+			continue
+		}
+
+		j.run(ctx, job)
+	}
+}
+
+// claim takes the lease on a popped job for the worker. False is reported if
+// another worker holds it, or if the job is not waiting to run, such as when
+// it was queued twice and has already been run.
+func (j *Jobs) claim(ctx context.Context, id string) (Job, bool) {
+	log := slog.With("job-id", id)
+
+	ok, err := j.cache.SetNX(ctx, keyJobLease.Key(id), time.Now(), jobStaleAfter)
+	if err != nil {
+		// Left for recover to queue again
+		log.Warn("Unable to lease job", "error", err)
+		return Job{}, false
+	} else if !ok {
+		return Job{}, false
+	}
+
+	job, err := j.Get(ctx, id)
+	if err != nil {
+		log.Warn("Skipping job that could not be loaded", "error", err)
+		j.release(ctx, id)
+		return Job{}, false
+	} else if job.Status != JobQueued {
+		j.release(ctx, id)
+		return Job{}, false
+	}
+
+	return job, true
+}
+
+// release gives up the lease on a job, once its status has been saved.
+func (j *Jobs) release(ctx context.Context, id string) {
+	if err := j.cache.Delete(context.WithoutCancel(ctx), keyJobLease.Key(id)); err != nil {
+		slog.Warn("Unable to release job", "job-id", id, "error", err)
+	}
+}
+
+// run performs a single attempt of a claimed job, saving its outcome.
+func (j *Jobs) run(ctx context.Context, job Job) {
+	log := slog.With("job-id", job.ID, "kind", job.Kind)
+	defer j.release(ctx, job.ID)
+
+	j.mx.RLock()
+	handler := j.handlers[job.Kind]
+	j.mx.RUnlock()
+
+	job.Status = JobRunning
+	job.Attempts++
+	if err := j.save(ctx, &job); err != nil {
+		log.Warn("Unable to save job status", "error", err)
+	}
+
+	// Progress is saved along with the heartbeat, rather than on every call
+	mx := sync.Mutex{}
+	progress := func(done, total int) {
+		mx.Lock()
+		defer mx.Unlock()
+		job.Done, job.Total = done, total
+	}
+
+	heartbeat := time.NewTicker(jobHeartbeat)
+	defer heartbeat.Stop()
+	done := make(chan error, 1)
+
+	log.Info("Running job", "attempt", job.Attempts)
+	go func() {
+		if handler == nil {
+			done <- fmt.Errorf("%w: %q", ErrUnknownJob, job.Kind)
+			return
+		}
+		done <- handler(ctx, job, progress)
+	}()
+
+	var err error
+	for running := true; running; {
+		select {
+		case err = <-done:
+			running = false
+		case <-heartbeat.C:
+			mx.Lock()
+			saveErr := j.save(ctx, &job)
+			mx.Unlock()
+			if saveErr != nil {
+				log.Warn("Unable to save job progress", "error", saveErr)
+			}
+			if err := j.cache.Set(ctx, keyJobLease.Key(job.ID), time.Now(), jobStaleAfter); err != nil {
+				log.Warn("Unable to extend job lease", "error", err)
+			}
+		}
+	}
+
+	mx.Lock()
+	defer mx.Unlock()
+
+	switch {
+	case err == nil:
+		log.Info("Job succeeded")
+		job.Status = JobSucceeded
+		job.Error = ""
+	case ctx.Err() != nil:
+		// Interrupted by shutdown. Leave it to be recovered by another replica
+		log.Warn("Job interrupted", "error", err)
+		return
+	case job.Attempts >= job.MaxAttempts:
+		log.Error("Job failed", "error", err)
+		job.Status = JobFailed
+		job.Error = err.Error()
+	default:
+		backoff := jobBackoff * time.Duration(1<<(job.Attempts-1))
+		log.Warn("Job errored. Retrying.", "error", err, "backoff", backoff)
+		job.Status = JobRetrying
+		job.Error = err.Error()
+		job.RetryAt = time.Now().Add(backoff)
+
+		time.AfterFunc(backoff, func() {
+			j.requeue(context.WithoutCancel(ctx), job)
+		})
+	}
+
+	if err := j.save(ctx, &job); err != nil {
+		log.Warn("Unable to save job status", "error", err)
+	}
+}
+
+// requeue queues a job to be run again.
+func (j *Jobs) requeue(ctx context.Context, job Job) {
+	job.Status = JobQueued
+	job.RetryAt = time.Time{}
+	if err := j.save(ctx, &job); err != nil {
+		slog.Warn("Unable to save job status", "job-id", job.ID, "error", err)
+		return
+	}
+	if err := j.queue.Push(ctx, keyJobQueue.Key(), job.ID); err != nil {
+		slog.Warn("Unable to queue job", "job-id", job.ID, "error", err)
+	}
+}
+
+// recover periodically queues jobs that were abandoned while running or
+// waiting to be retried, or that were popped from the queue by a worker that
+// stopped before running them.
+func (j *Jobs) recover(ctx context.Context) {
+	tick := time.NewTicker(jobStaleAfter)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+
+		if err := j.recoverAbandoned(ctx); err != nil {
+			slog.Warn("Unable to scan for abandoned jobs", "error", err)
+		}
+	}
+}
+
+// recoverAbandoned queues every abandoned job again.
+func (j *Jobs) recoverAbandoned(ctx context.Context) error {
+	pending, err := j.queue.Pending(ctx, keyJobQueue.Key())
+	if err != nil {
+		return fmt.Errorf("could not list queued jobs: %w", err)
+	}
+	inQueue := map[string]bool{}
+	for _, id := range pending {
+		inQueue[id] = true
+	}
+
+	keys, err := j.cache.Keys(ctx, keyJob.Pattern())
+	if err != nil {
+		return fmt.Errorf("could not list jobs: %w", err)
+	}
+
+	for _, key := range keys {
+		job := Job{}
+		if err := j.cache.Get(ctx, key, &job); err != nil {
+			continue
+		}
+
+		switch {
+		case job.Status == JobRunning && time.Since(job.UpdatedAt) > jobStaleAfter:
+		case job.Status == JobRetrying && time.Since(job.RetryAt) > jobStaleAfter:
+		case job.Status == JobQueued && time.Since(job.UpdatedAt) > jobStaleAfter && !inQueue[job.ID]:
+		default:
+			continue
+		}
+
+		// A leased job is still being run, or about to be
+		if leased, err := j.cache.Has(ctx, keyJobLease.Key(job.ID)); err != nil || leased {
+			continue
+		}
+
+		slog.Warn("Recovering abandoned job", "job-id", job.ID, "kind", job.Kind, "status", job.Status)
+		j.requeue(ctx, job)
+	}
+
+	return nil
+}
+
+func (j *Jobs) save(ctx context.Context, job *Job) error {
+	job.UpdatedAt = time.Now()
+	return j.cache.Set(ctx, keyJob.Key(job.ID), job, jobTTL)
+}
+
+// Kinds of job run by the data layer.
+const (
+	// JobRefreshSummary refreshes the progress summary of the user given in
+	// the "steam-id" argument.
+	JobRefreshSummary = "refresh-summary"
+	// JobUpdateSummary updates the out of date games in the progress summary
+	// of the user given in the "steam-id" argument.
+	JobUpdateSummary = "update-summary"
+	// JobDeliverWebhook delivers the "notification" argument to the user's
+	// webhook given in the "steam-id" and "webhook-id" arguments.
+	JobDeliverWebhook = "deliver-webhook"
+)
+
+// SetJobs registers the data layer's job handlers, allowing them to be
+// enqueued through EnqueueJob.
+func (d *Data) SetJobs(jobs *Jobs) {
+	jobs.Handle(JobRefreshSummary, func(ctx context.Context, job Job, progress func(done, total int)) error {
+		_, err := d.RefreshSummary(ctx, job.Args["steam-id"], progress)
+		return err
+	})
+	jobs.Handle(JobUpdateSummary, func(ctx context.Context, job Job, _ func(done, total int)) error {
+		_, err := d.updateQueuedSummary(ctx, job.Args["steam-id"])
+		return err
+	})
+	jobs.Handle(JobDeliverWebhook, func(ctx context.Context, job Job, _ func(done, total int)) error {
+		return d.deliverWebhook(ctx, job)
+	})
+
+	d.jobs = jobs
+}
+
+// EnqueueJob queues a job to be run in the background.
+func (d *Data) EnqueueJob(ctx context.Context, kind string, args map[string]string) (Job, error) {
+	if d.jobs == nil {
+		return Job{}, fmt.Errorf("background jobs are not enabled")
+	}
+	return d.jobs.Enqueue(ctx, kind, args)
+}
+
+// GetJob returns the status of a job.
+func (d *Data) GetJob(ctx context.Context, id string) (Job, error) {
+	if d.jobs == nil {
+		return Job{}, fmt.Errorf("background jobs are not enabled")
+	}
+	return d.jobs.Get(ctx, id)
+}
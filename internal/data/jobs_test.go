@@ -0,0 +1,115 @@
+package data
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+)
+
+func TestJobsRunQueuedTwiceOnce(t *testing.T) {
+	c := cache.NewMemory()
+	jobs := NewJobs(c, c)
+
+	runs := atomic.Int32{}
+	release := make(chan struct{})
+	jobs.Handle("test", func(ctx context.Context, job Job, _ func(done, total int)) error {
+		runs.Add(1)
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	job, err := jobs.Enqueue(ctx, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Such as when a job is recovered while it is still queued
+	if err := c.Push(ctx, keyJobQueue.Key(), job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		jobs.Run(ctx, 4)
+	}()
+
+	waitForJob(t, jobs, job.ID, JobRunning)
+	// Give the other workers the chance to pop the duplicate
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	waitForJob(t, jobs, job.ID, JobSucceeded)
+
+	cancel()
+	<-done
+	if got := runs.Load(); got != 1 {
+		t.Errorf("job ran %d times, want once", got)
+	}
+}
+
+func TestJobsRecoverLostQueuedJob(t *testing.T) {
+	c := cache.NewMemory()
+	jobs := NewJobs(c, c)
+	jobs.Handle("test", func(ctx context.Context, job Job, _ func(done, total int)) error {
+		return nil
+	})
+	ctx := context.Background()
+
+	job, err := jobs.Enqueue(ctx, "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Popped by a replica that stopped before claiming it
+	if _, err := c.Pop(ctx, keyJobQueue.Key(), time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// Recently queued jobs are left alone, as they may be about to be claimed
+	if err := jobs.recoverAbandoned(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := c.Pending(ctx, keyJobQueue.Key()); len(pending) != 0 {
+		t.Fatalf("got %v queued, want a fresh job left alone", pending)
+	}
+
+	job.UpdatedAt = time.Now().Add(-2 * jobStaleAfter)
+	if err := c.Set(ctx, keyJob.Key(job.ID), job, jobTTL); err != nil {
+		t.Fatal(err)
+	}
+	if err := jobs.recoverAbandoned(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := c.Pending(ctx, keyJobQueue.Key()); len(pending) != 1 || pending[0] != job.ID {
+		t.Fatalf("got %v queued, want the lost job queued again", pending)
+	}
+
+	// Jobs still in the queue are not queued twice
+	if err := jobs.recoverAbandoned(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if pending, _ := c.Pending(ctx, keyJobQueue.Key()); len(pending) != 1 {
+		t.Fatalf("got %v queued, want the job queued once", pending)
+	}
+}
+
+// waitForJob waits for the job to reach the status.
+func waitForJob(t *testing.T, jobs *Jobs, id string, status JobStatus) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := jobs.Get(context.Background(), id)
+		if err == nil && job.Status == status {
+			return
+		} else if time.Now().After(deadline) {
+			t.Fatalf("job did not become %s: %+v", status, job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,61 @@
+package data
+
+import "github.com/taiidani/achievements/internal/data/cache"
+
+// Cache key families for everything stored by this package. Bump a family's
+// Version whenever the type stored under it changes shape, so that entries
+// written by a previous deploy are ignored rather than decoded incorrectly.
+var (
+	keyGameGlobal              = cache.KeyFamily{Format: "game:%d:global", Version: 2}
+	keyGameSchema              = cache.KeyFamily{Format: "game:%d:schema", Version: 2}
+	keyGameDetails             = cache.KeyFamily{Format: "game:%d:details", Version: 1}
+	keyPlayerSummary           = cache.KeyFamily{Format: "player:%s:summary", Version: 2}
+	keyPlayerAchievements      = cache.KeyFamily{Format: "player:%s:game:%d:achievements", Version: 2}
+	keyPlayerGames             = cache.KeyFamily{Format: "player:%s:games", Version: 2}
+	keyPlayerVanity            = cache.KeyFamily{Format: "player:%s:vanity", Version: 2}
+	keyPlayerStats             = cache.KeyFamily{Format: "player:%s:stats", Version: 1}
+	keyPlayerTimeline          = cache.KeyFamily{Format: "player:%s:timeline", Version: 1}
+	keyPlayerProgress          = cache.KeyFamily{Format: "player:%s:progress", Version: 1}
+	keyPlayerProgressUpdate    = cache.KeyFamily{Format: "player:%s:progress-update", Version: 1}
+	keyPlayerWebhooks          = cache.KeyFamily{Format: "player:%s:webhooks", Version: 1}
+	keyPlayerWebhookDeliveries = cache.KeyFamily{Format: "player:%s:webhook-deliveries", Version: 1}
+	keyPlayerGoals             = cache.KeyFamily{Format: "player:%s:goals", Version: 1}
+	keyPlayerGroups            = cache.KeyFamily{Format: "player:%s:groups", Version: 1}
+	keyActiveUser              = cache.KeyFamily{Format: "active:%s", Version: 1}
+	keySession                 = cache.KeyFamily{Format: "session:%s", Version: 1}
+	keyGroup                   = cache.KeyFamily{Format: "group:%s", Version: 1}
+	keyJob                     = cache.KeyFamily{Format: "job:%s", Version: 1}
+	keyJobQueue                = cache.KeyFamily{Format: "jobs:queue", Version: 1}
+	keyJobLease                = cache.KeyFamily{Format: "jobs:lease:%s", Version: 1}
+	keyLock                    = cache.KeyFamily{Format: "lock:%s", Version: 1}
+	keyRefreshLease            = cache.KeyFamily{Format: "refresh:lease:%s", Version: 1}
+)
+
+// KeyFamilies lists every cache key family stored by this package.
+func KeyFamilies() []cache.KeyFamily {
+	return []cache.KeyFamily{
+		keyGameGlobal,
+		keyGameSchema,
+		keyGameDetails,
+		keyPlayerSummary,
+		keyPlayerAchievements,
+		keyPlayerGames,
+		keyPlayerVanity,
+		keyPlayerStats,
+		keyPlayerTimeline,
+		keyPlayerProgress,
+		keyPlayerProgressUpdate,
+		keyPlayerWebhooks,
+		keyPlayerWebhookDeliveries,
+		keyPlayerGoals,
+		keyPlayerGroups,
+		keyActiveUser,
+		keySession,
+		keyGroup,
+		keyJob,
+		keyJobQueue,
+		keyJobLease,
+		keyLock,
+		keyRefreshLease,
+	}
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// lockTTL bounds how long a lock is held should its holder fail to release
+	// it. Locks are only held while a record is read, changed and saved.
+	lockTTL = time.Second * 10
+	// lockWait is how long to wait for a lock held by another request.
+	lockWait = time.Second * 5
+	// lockRetry is how often a held lock is tried again.
+	lockRetry = time.Millisecond * 25
+)
+
+// ErrLocked is returned when a record is being changed by another request for
+// longer than lockWait.
+var ErrLocked = errors.New("this is being changed by another request. Please try again")
+
+// lock serializes changes to the record stored under key across every replica
+// sharing the cache, returning the function that releases the lock.
+//
+// Locks are taken with SetNX and released by deleting them, unless they have
+// since expired and been taken by another request.
+func (d *Data) lock(ctx context.Context, key string) (func(), error) {
+	token, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	lockKey := keyLock.Key(key)
+	deadline := time.Now().Add(lockWait)
+	for {
+		ok, err := d.cache.SetNX(ctx, lockKey, token, lockTTL)
+		if err != nil {
+			return nil, fmt.Errorf("could not lock %q: %w", key, err)
+		} else if ok {
+			break
+		} else if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetry):
+		}
+	}
+
+	return func() {
+		ctx := context.WithoutCancel(ctx)
+		holder := ""
+		if err := d.cache.Get(ctx, lockKey, &holder); err != nil || holder != token {
+			return
+		}
+		if err := d.cache.Delete(ctx, lockKey); err != nil {
+			slog.Warn("Unable to release lock", "key", key, "error", err)
+		}
+	}, nil
+}
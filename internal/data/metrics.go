@@ -0,0 +1,44 @@
+package data
+
+import (
+	"github.com/taiidani/achievements/internal/data/cache"
+)
+
+// CacheMetrics describes how the cache has been used since startup.
+type CacheMetrics struct {
+	// Families is keyed by key family name, and is empty unless the cache is
+	// instrumented.
+	Families map[string]cache.FamilyStats
+	// Tiers is keyed by tier name, and is empty unless the cache is tiered.
+	Tiers map[string]cache.TierStats
+	// Healthy is unset while the cache's backend cannot be reached.
+	Healthy bool
+}
+
+func (d *Data) CacheMetrics() CacheMetrics {
+	ret := CacheMetrics{
+		Families: map[string]cache.FamilyStats{},
+		Tiers:    map[string]cache.TierStats{},
+		Healthy:  true,
+	}
+
+	// Walk down the chain of decorators, collecting what each one reports
+	c := d.cache
+	for c != nil {
+		switch typed := c.(type) {
+		case *cache.Instrumented:
+			ret.Families = typed.Stats()
+			c = typed.Unwrap()
+		case *cache.Tiered:
+			ret.Tiers = typed.Stats()
+			c = typed.Unwrap()
+		case *cache.Redis:
+			ret.Healthy = typed.Healthy()
+			c = nil
+		default:
+			c = nil
+		}
+	}
+
+	return ret
+}
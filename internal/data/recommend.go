@@ -0,0 +1,120 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Recommendation is a locked achievement suggested as one to chase next.
+type Recommendation struct {
+	Game        Game
+	Achievement Achievement
+	// Completion is the percentage of the game's achievements already
+	// unlocked.
+	Completion int
+	// Rank orders recommendations, with higher ranks being easier to chase.
+	Rank float64
+}
+
+// RecommendOption filters the games that Recommend considers.
+type RecommendOption func(*recommendOptions)
+
+type recommendOptions struct {
+	playedWithin  time.Duration
+	minCompletion int
+}
+
+// PlayedWithin excludes games that have not been played within the duration.
+func PlayedWithin(d time.Duration) RecommendOption {
+	return func(o *recommendOptions) {
+		o.playedWithin = d
+	}
+}
+
+// MinCompletion excludes games with fewer than the given percentage of their
+// achievements unlocked.
+func MinCompletion(pct int) RecommendOption {
+	return func(o *recommendOptions) {
+		o.minCompletion = pct
+	}
+}
+
+// Weights of each factor in a recommendation's rank. They sum to 1.
+const (
+	// rankEase favors achievements that many players have unlocked
+	rankEase = 0.5
+	// rankProximity favors games that are close to being completed
+	rankProximity = 0.25
+	// rankRecency favors games that have been played recently
+	rankRecency = 0.15
+	// rankVisible favors achievements whose requirements are not hidden
+	rankVisible = 0.1
+)
+
+// recencyHalfLife is how long after a game was last played that its recency
+// factor halves.
+const recencyHalfLife = time.Hour * 24 * 30
+
+// Recommend ranks the user's locked achievements across every game they have
+// played, returning up to limit of the easiest to chase next.
+func (d *Data) Recommend(ctx context.Context, userID string, limit int, opts ...RecommendOption) ([]Recommendation, error) {
+	o := recommendOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	games, err := d.GetAllAchievements(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("could not build recommendations for %q: %w", userID, err)
+	}
+
+	ret := []Recommendation{}
+	for _, game := range games {
+		completion := game.Achievements.AchievementUnlockedPercentage
+		if completion == 100 || completion < o.minCompletion {
+			continue
+		} else if o.playedWithin > 0 && (game.Game.LastPlayed.IsZero() || time.Since(game.Game.LastPlayed) > o.playedWithin) {
+			continue
+		}
+
+		recency := 0.0
+		if !game.Game.LastPlayed.IsZero() {
+			recency = 1 / (1 + float64(time.Since(game.Game.LastPlayed))/float64(recencyHalfLife))
+		}
+
+		for _, achievement := range game.Achievements.Achievements {
+			if achievement.Achieved {
+				continue
+			}
+
+			rank := rankEase*achievement.GlobalPercentage/100 +
+				rankProximity*float64(completion)/100 +
+				rankRecency*recency
+			if !achievement.Hidden {
+				rank += rankVisible
+			}
+
+			ret = append(ret, Recommendation{
+				Game:        game.Game,
+				Achievement: achievement,
+				Completion:  completion,
+				Rank:        rank,
+			})
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Rank != ret[j].Rank {
+			return ret[i].Rank > ret[j].Rank
+		}
+		return ret[i].Achievement.Name < ret[j].Achievement.Name
+	})
+
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,70 @@
+package data
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRecommend(t *testing.T) {
+	f := newFakeSteam()
+	now := time.Now()
+	f.users["1"] = []fakeGame{
+		{ID: 1, Name: "Nearly", Achievements: 4, Unlocked: 3, UnlockedAt: now.Add(-time.Hour), LastPlayed: now},
+		{ID: 2, Name: "Started", Achievements: 4, Unlocked: 1, UnlockedAt: now.Add(-time.Hour), LastPlayed: now},
+		{ID: 3, Name: "Perfect", Achievements: 2, Unlocked: 2, UnlockedAt: now.Add(-time.Hour), LastPlayed: now},
+		{ID: 4, Name: "Abandoned", Achievements: 2, LastPlayed: now.AddDate(-1, 0, 0)},
+	}
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	type recommended struct {
+		gameID  uint64
+		apiName string
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		opts  []RecommendOption
+		want  []recommended
+	}{
+		{
+			name: "all",
+			want: []recommended{{1, "A3"}, {2, "A1"}, {2, "A2"}, {2, "A3"}, {4, "A0"}, {4, "A1"}},
+		},
+		{
+			name:  "limited",
+			limit: 2,
+			want:  []recommended{{1, "A3"}, {2, "A1"}},
+		},
+		{
+			name: "played within",
+			opts: []RecommendOption{PlayedWithin(time.Hour * 24 * 30)},
+			want: []recommended{{1, "A3"}, {2, "A1"}, {2, "A2"}, {2, "A3"}},
+		},
+		{
+			name: "min completion",
+			opts: []RecommendOption{MinCompletion(50)},
+			want: []recommended{{1, "A3"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recommendations, err := d.Recommend(ctx, "1", tt.limit, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []recommended{}
+			for _, r := range recommendations {
+				got = append(got, recommended{r.Game.ID, r.Achievement.APIName})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,58 +2,294 @@ package data
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
 	"time"
-
-	"github.com/taiidani/achievements/internal/data/cache"
-	"github.com/taiidani/achievements/internal/steam"
 )
 
-func Refresher(ctx context.Context, client *steam.Client, cache cache.Cache) {
-	tick := time.NewTicker(time.Hour * 24)
+// RefresherOptions configures the background Refresher.
+type RefresherOptions struct {
+	// ActiveWithin is how recently a user must have been seen for their data
+	// to be refreshed.
+	ActiveWithin time.Duration
+	// UserInterval is how often active users' owned games, and achievements
+	// in recently played games, are refreshed.
+	UserInterval time.Duration
+	// GlobalInterval is how often the global achievement percentages of every
+	// cached app are refreshed.
+	GlobalInterval time.Duration
+	// SchemaInterval is how often the achievement schemas of every cached app
+	// are refreshed.
+	SchemaInterval time.Duration
+	// Workers bounds how many refreshes run at once.
+	Workers int
+	// Jitter randomizes each interval by up to this fraction of it in either
+	// direction, so that replicas do not refresh in lockstep.
+	Jitter float64
+}
+
+// DefaultRefresherOptions refreshes the users seen in the past week hourly,
+// and every app daily.
+var DefaultRefresherOptions = RefresherOptions{
+	ActiveWithin:   time.Hour * 24 * 7,
+	UserInterval:   time.Hour,
+	GlobalInterval: time.Hour * 24,
+	SchemaInterval: time.Hour * 24,
+	Workers:        4,
+	Jitter:         0.1,
+}
+
+// Refresher keeps cached Steam data up to date in the background, so that
+// pages are rarely left waiting on Steam. Each kind of data is refreshed on
+// its own schedule, with the individual refreshes shared between a bounded
+// pool of workers. Results are written through the cache layer, as if they had
+// been loaded by a page.
+type Refresher struct {
+	data *Data
+	opts RefresherOptions
+	jobs chan func(context.Context)
+}
+
+func NewRefresher(data *Data, opts RefresherOptions) *Refresher {
+	return &Refresher{
+		data: data,
+		opts: opts,
+		jobs: make(chan func(context.Context)),
+	}
+}
 
-	err := refreshData(ctx, client, cache)
+// Run refreshes data until the context is cancelled.
+func (r *Refresher) Run(ctx context.Context) {
+	wg := sync.WaitGroup{}
+	for range max(r.opts.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+
+	schedules := []struct {
+		name     string
+		interval time.Duration
+		run      func(ctx context.Context, since time.Time) error
+	}{
+		{"users", r.opts.UserInterval, r.refreshUsers},
+		{"globals", r.opts.GlobalInterval, r.refreshGlobals},
+		{"schemas", r.opts.SchemaInterval, r.refreshSchemas},
+	}
+	for _, schedule := range schedules {
+		if schedule.interval <= 0 {
+			slog.Info("Refresh disabled", "job", schedule.name)
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.schedule(ctx, schedule.name, schedule.interval, schedule.run)
+		}()
+	}
+
+	wg.Wait()
+	slog.Info("Data refresher exited")
+}
+
+// schedule calls run immediately and then every interval, passing the time
+// that the previous run on this replica began. Only one replica runs each
+// interval, with the others skipping it.
+func (r *Refresher) schedule(ctx context.Context, name string, interval time.Duration, run func(context.Context, time.Time) error) {
+	log := slog.With("job", name)
+	since := time.Now().Add(-r.opts.ActiveWithin)
+
+	for {
+		if r.lease(ctx, name, interval) {
+			start := time.Now()
+			log.Info("Refreshing data")
+			if err := run(ctx, since); err != nil {
+				log.Error("Refresh cycle errored", "error", err)
+			} else {
+				log.Info("Refresh complete", "duration", time.Since(start))
+			}
+			since = start
+		} else {
+			log.Debug("Refresh already run by another replica")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.jitter(interval)):
+		}
+	}
+}
+
+// lease claims the refresh for the current interval across every replica
+// sharing the cache. It is never released, instead expiring shortly before the
+// next interval so that the first replica to wake for it runs it. Refreshes
+// run regardless should the cache be unavailable.
+func (r *Refresher) lease(ctx context.Context, name string, interval time.Duration) bool {
+	ttl := time.Duration(float64(interval) * max(1-r.opts.Jitter, 0.5))
+	ok, err := r.data.cache.SetNX(ctx, keyRefreshLease.Key(name), time.Now(), ttl)
 	if err != nil {
-		slog.Error("refresh cycle errored", "error", err)
+		slog.Warn("Unable to lease refresh. Running it anyway.", "job", name, "error", err)
+		return true
 	}
+	return ok
+}
+
+func (r *Refresher) jitter(interval time.Duration) time.Duration {
+	spread := float64(interval) * r.opts.Jitter
+	return interval + time.Duration((rand.Float64()*2-1)*spread)
+}
 
+func (r *Refresher) work(ctx context.Context) {
 	for {
 		select {
 		case <-ctx.Done():
-			slog.Info("Data refresher exited")
 			return
-		case <-tick.C:
-			err = refreshData(ctx, client, cache)
+		case job := <-r.jobs:
+			job(ctx)
+		}
+	}
+}
+
+// enqueue hands a refresh to the worker pool, blocking until a worker is free.
+func (r *Refresher) enqueue(ctx context.Context, job func(context.Context)) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case r.jobs <- job:
+		return true
+	}
+}
+
+// refreshUsers refreshes the owned games of each active user, and their
+// achievements in any game played since the previous run. New unlocks are
+// recorded in their history, notifying their webhooks, and their progress
+// summary is then brought up to date from the refreshed data.
+//
+// Users with webhooks are refreshed whether or not they are active, so that
+// they continue to be notified.
+func (r *Refresher) refreshUsers(ctx context.Context, since time.Time) error {
+	users, err := r.data.ActiveUsers(ctx, r.opts.ActiveWithin)
+	if err != nil {
+		return err
+	}
+
+	subscribers, err := r.data.WebhookUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, userID := range subscribers {
+		if !slices.Contains(users, userID) {
+			users = append(users, userID)
+		}
+	}
+
+	wg := sync.WaitGroup{}
+	for _, userID := range users {
+		log := slog.With("steam-id", userID)
+
+		wg.Add(1)
+		ok := r.enqueue(ctx, func(ctx context.Context) {
+			defer wg.Done()
+
+			if _, err := r.data.steam.RefreshPlayerOwnedGames(ctx, userID); err != nil {
+				log.Warn("Failed to refresh owned games", "error", err)
+				return
+			}
+
+			games, err := r.data.GetGames(ctx, userID)
 			if err != nil {
-				slog.Error("refresh cycle errored", "error", err)
+				log.Warn("Failed to list owned games", "error", err)
+				return
+			}
+
+			for _, game := range games {
+				if game.LastPlayed.Before(since) {
+					continue
+				}
+
+				// Enqueued separately, as this worker cannot wait on the pool
+				wg.Add(1)
+				go func() {
+					ok := r.enqueue(ctx, func(ctx context.Context) {
+						defer wg.Done()
+						// History is recorded as the achievements are fetched
+						if _, err := r.data.steam.RefreshPlayerAchievements(ctx, userID, game.ID); err != nil {
+							log.Warn("Failed to refresh player achievements", "game-id", game.ID, "error", err)
+						}
+					})
+					if !ok {
+						wg.Done()
+					}
+				}()
 			}
+		})
+		if !ok {
+			wg.Done()
+			break
+		}
+	}
+	wg.Wait()
+
+	// Refreshing achievements is already enough to bring each summary up to
+	// date, as they are recomputed for games played since they last were
+	for _, userID := range users {
+		if _, err := r.data.GetSummary(ctx, userID); err != nil {
+			slog.Warn("Failed to update summary", "steam-id", userID, "error", err)
 		}
 	}
-}
 
-func refreshData(ctx context.Context, client *steam.Client, cache cache.Cache) error {
-	slog.Info("Refreshing data")
+	return ctx.Err()
+}
 
-	start := time.Now()
-	defer func() {
-		slog.Info("Refresh complete", "duration", time.Since(start))
-	}()
+// refreshGlobals refreshes the global achievement percentages of every app in
+// the cache.
+func (r *Refresher) refreshGlobals(ctx context.Context, _ time.Time) error {
+	appIDs, err := r.data.steam.GetGlobalsInCache(ctx)
+	if err != nil {
+		return err
+	}
 
-	d := NewData(client, cache)
+	return r.refreshApps(ctx, appIDs, func(ctx context.Context, appID uint64) error {
+		_, err := r.data.steam.RefreshGlobalAchievementPercentagesForApp(ctx, appID)
+		return err
+	})
+}
 
-	appIDs, err := d.steam.GetSchemasInCache(ctx)
+// refreshSchemas refreshes the achievement schemas of every app in the cache.
+func (r *Refresher) refreshSchemas(ctx context.Context, _ time.Time) error {
+	appIDs, err := r.data.steam.GetSchemasInCache(ctx)
 	if err != nil {
-		return fmt.Errorf("unable to get schemas in cache: %w", err)
+		return err
 	}
 
+	return r.refreshApps(ctx, appIDs, func(ctx context.Context, appID uint64) error {
+		_, err := r.data.steam.RefreshSchemaForGame(ctx, appID)
+		return err
+	})
+}
+
+func (r *Refresher) refreshApps(ctx context.Context, appIDs []uint64, refresh func(context.Context, uint64) error) error {
+	wg := sync.WaitGroup{}
 	for _, appID := range appIDs {
-		// We don't need the data itself; we're just refreshing its cache
-		_, err := client.ISteamUserStats.GetSchemaForGame(ctx, appID)
-		if err != nil {
-			slog.Warn("Failed to get schema for game", "appID", appID, "error", err)
+		wg.Add(1)
+		ok := r.enqueue(ctx, func(ctx context.Context) {
+			defer wg.Done()
+			if err := refresh(ctx, appID); err != nil {
+				slog.Warn("Failed to refresh app", "app-id", appID, "error", err)
+			}
+		})
+		if !ok {
+			wg.Done()
+			break
 		}
 	}
+	wg.Wait()
 
-	return nil
+	return ctx.Err()
 }
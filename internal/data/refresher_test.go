@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/taiidani/achievements/internal/steam"
+)
+
+// TestRefresherRunsOnce guards against every replica running each refresh.
+func TestRefresherRunsOnce(t *testing.T) {
+	d := newTestData(t, newFakeSteam())
+	replica := NewData(steam.NewClient(), d.cache, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runs := atomic.Int32{}
+	wg := sync.WaitGroup{}
+	for _, backend := range []*Data{d, replica} {
+		r := NewRefresher(backend, DefaultRefresherOptions)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.schedule(ctx, "test", time.Hour, func(context.Context, time.Time) error {
+				runs.Add(1)
+				return nil
+			})
+		}()
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	cancel()
+	wg.Wait()
+
+	if got := runs.Load(); got != 1 {
+		t.Errorf("got %d runs, want 1", got)
+	}
+}
+
+func TestRefreshUsers(t *testing.T) {
+	f := newFakeSteam()
+	now := time.Now()
+	f.users["1"] = []fakeGame{
+		{ID: 1, Name: "Recent", Achievements: 2, Unlocked: 1, UnlockedAt: now.Add(-time.Hour), LastPlayed: now.Add(-time.Minute)},
+		{ID: 2, Name: "Old", Achievements: 2, LastPlayed: now.Add(-time.Hour * 24 * 30)},
+	}
+	f.users["2"] = []fakeGame{
+		{ID: 3, Name: "Inactive", Achievements: 2, LastPlayed: now.Add(-time.Minute)},
+	}
+	d := newTestData(t, f)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.markActive(ctx, "1")
+
+	r := NewRefresher(d, DefaultRefresherOptions)
+	go r.work(ctx)
+
+	if err := r.refreshUsers(ctx, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the active user is refreshed
+	if got := f.count("GetOwnedGames"); got != 1 {
+		t.Errorf("got %d owned games requests, want 1", got)
+	}
+	achievements := f.count("GetPlayerAchievements")
+	if achievements == 0 {
+		t.Error("got no achievements requests, want the recent game refreshed")
+	}
+
+	// The summary is brought up to date from the refreshed data
+	summary := Summary{}
+	if err := d.cache.Get(ctx, keyPlayerProgress.Key("1"), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if got := summary.Games[1].Unlocked; got != 1 {
+		t.Errorf("got %d unlocked in the recent game, want 1", got)
+	}
+}
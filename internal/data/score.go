@@ -0,0 +1,86 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// Scorer values an achievement by how rare it is, given the percentage of
+// players that have unlocked it.
+type Scorer interface {
+	Points(globalPercentage float64) int
+}
+
+// ScorerFunc adapts a function into a Scorer.
+type ScorerFunc func(globalPercentage float64) int
+
+func (f ScorerFunc) Points(globalPercentage float64) int {
+	return f(globalPercentage)
+}
+
+// basePoints is the value of an achievement that every player has unlocked.
+const basePoints = 10
+
+// minPercentage bounds how rare an achievement is considered to be, so that
+// achievements unlocked by a handful of players are not worth unbounded
+// points.
+const minPercentage = 0.1
+
+// scorers are the available scoring strategies, by name.
+var scorers = map[string]Scorer{
+	// flat values every achievement equally, matching raw counts
+	"flat": ScorerFunc(func(float64) int {
+		return basePoints
+	}),
+
+	// inverse-rarity scales points by the square root of the inverse of the
+	// unlock rate, in the style of TrueAchievements. An achievement unlocked
+	// by 1% of players is worth 10 times one unlocked by everybody.
+	"inverse-rarity": ScorerFunc(func(pct float64) int {
+		return int(math.Round(basePoints * math.Sqrt(100/clampPercentage(pct))))
+	}),
+
+	// logarithmic adds a fixed number of points each time the unlock rate
+	// halves, rewarding rare achievements more gently.
+	"logarithmic": ScorerFunc(func(pct float64) int {
+		return int(math.Round(basePoints * (1 + math.Log2(100/clampPercentage(pct)))))
+	}),
+}
+
+// DefaultScorer is the name of the scoring strategy used unless another is
+// set with SetScorer.
+const DefaultScorer = "inverse-rarity"
+
+// ParseScorer returns the scoring strategy with the given name.
+func ParseScorer(name string) (Scorer, error) {
+	ret, ok := scorers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scorer %q", name)
+	}
+	return ret, nil
+}
+
+// ScorerNames lists the names of every available scoring strategy.
+func ScorerNames() []string {
+	ret := []string{}
+	for name := range scorers {
+		ret = append(ret, name)
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// SetScorer changes the strategy used to value achievements.
+func (d *Data) SetScorer(scorer Scorer) {
+	d.scorer = scorer
+}
+
+// clampPercentage treats unknown percentages, reported as 0, as achievements
+// that everybody has unlocked, and bounds the rest by minPercentage.
+func clampPercentage(pct float64) float64 {
+	if pct <= 0 {
+		return 100
+	}
+	return min(max(pct, minPercentage), 100)
+}
@@ -13,17 +13,21 @@ type Session struct {
 const DefaultSessionExpiration = time.Hour * 24 * 90
 
 func (d *Data) GetSession(ctx context.Context, key string) (*Session, error) {
-	key = "session:" + key
-	if ok, _ := d.cache.Has(ctx, key); !ok {
-		return nil, nil
+	cacheKey := keySession.Key(key)
+	if ok, _ := d.cache.Has(ctx, cacheKey); !ok {
+		// Sessions created before cache keys were versioned are still honored
+		// until they expire, rather than logging everybody out
+		cacheKey = "session:" + key
+		if ok, _ := d.cache.Has(ctx, cacheKey); !ok {
+			return nil, nil
+		}
 	}
 
 	ret := &Session{}
-	err := d.cache.Get(ctx, key, ret)
+	err := d.cache.Get(ctx, cacheKey, ret)
 	return ret, err
 }
 
 func (d *Data) SetSession(ctx context.Context, key string, sess Session) error {
-	key = "session:" + key
-	return d.cache.Set(ctx, key, sess, DefaultSessionExpiration)
+	return d.cache.Set(ctx, keySession.Key(key), sess, DefaultSessionExpiration)
 }
@@ -0,0 +1,240 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+	"github.com/taiidani/achievements/internal/steam"
+	"golang.org/x/sync/errgroup"
+)
+
+// statsMonths is the number of months, including the current one, that unlocks
+// are counted for.
+const statsMonths = 24
+
+// statsRecent is the period that RecentUnlocks are counted over.
+const statsRecent = time.Hour * 24 * 30
+
+// UserStats summarizes a user's achievements across every game they have
+// played.
+type UserStats struct {
+	TotalUnlocked int
+	// RecentUnlocks counts the achievements unlocked in the last 30 days.
+	RecentUnlocks int
+	// GamesStarted counts the games with at least one achievement unlocked.
+	GamesStarted int
+	// AverageCompletion is the mean percentage of achievements unlocked across
+	// started games.
+	AverageCompletion int
+	PerfectGames      int
+	// Points is the score of every unlocked achievement.
+	Points int
+	// Scores totals the unlocked achievements under every scoring strategy,
+	// for comparison.
+	Scores []ScoreTotal
+	// Rarest is the unlocked achievement with the lowest global unlock
+	// percentage, if any.
+	Rarest          *RareAchievement
+	UnlocksPerMonth []MonthUnlocks
+	// Genres are ordered by the number of started games in them.
+	Genres []GenreCompletion
+	// GenresIncomplete is set when the store details of some games could not
+	// be loaded, such as while the store is rate limiting requests.
+	GenresIncomplete bool
+	cache.Freshness
+}
+
+// Incomplete reports whether some of the stats are missing, for them to be
+// computed again rather than cached for their full TTL.
+func (s UserStats) Incomplete() bool {
+	return s.GenresIncomplete
+}
+
+type ScoreTotal struct {
+	Scorer string
+	Points int
+}
+
+type RareAchievement struct {
+	Game        Game
+	Achievement Achievement
+}
+
+type MonthUnlocks struct {
+	Month time.Time
+	Count int
+}
+
+type GenreCompletion struct {
+	Genre      string
+	Games      int
+	Unlocked   int
+	Total      int
+	Percentage int
+}
+
+// GetUserStats computes the user's account-wide statistics. They are cached
+// for an hour, as they are costly to compute.
+func (d *Data) GetUserStats(ctx context.Context, userID string) (UserStats, error) {
+	ctx, freshness := cache.TrackFreshness(ctx)
+
+	key := keyPlayerStats.Key(userID)
+	ret, err := cache.GetOrLoad(ctx, d.cache, key, time.Hour, func(ctx context.Context) (UserStats, error) {
+		return d.computeUserStats(ctx, userID)
+	}, cache.StaleFor(time.Hour*24))
+	if err != nil {
+		return UserStats{}, fmt.Errorf("could not compute stats for %q: %w", userID, err)
+	}
+
+	ret.Freshness = freshness()
+	return ret, nil
+}
+
+func (d *Data) computeUserStats(ctx context.Context, userID string) (UserStats, error) {
+	games, err := d.GetAllAchievements(ctx, userID)
+	if err != nil {
+		return UserStats{}, err
+	}
+
+	ret := UserStats{}
+	started := []GameAchievements{}
+	completion := 0
+
+	now := time.Now()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	months := map[time.Time]int{}
+
+	for _, game := range games {
+		if game.Achievements.AchievementUnlockedCount == 0 {
+			continue
+		}
+
+		started = append(started, game)
+		ret.TotalUnlocked += game.Achievements.AchievementUnlockedCount
+		ret.Points += game.Achievements.Points
+		completion += game.Achievements.AchievementUnlockedPercentage
+		if game.Achievements.AchievementUnlockedCount == game.Achievements.AchievementTotalCount {
+			ret.PerfectGames++
+		}
+
+		for _, achievement := range game.Achievements.Achievements {
+			if !achievement.Achieved {
+				continue
+			}
+
+			// Achievements without global percentages report 0, and are
+			// not truly the rarest
+			if achievement.GlobalPercentage > 0 && (ret.Rarest == nil || achievement.GlobalPercentage < ret.Rarest.Achievement.GlobalPercentage) {
+				ret.Rarest = &RareAchievement{Game: game.Game, Achievement: achievement}
+			}
+
+			if achievement.UnlockedOn != nil {
+				on := achievement.UnlockedOn
+				months[time.Date(on.Year(), on.Month(), 1, 0, 0, 0, 0, now.Location())]++
+				if now.Sub(*on) <= statsRecent {
+					ret.RecentUnlocks++
+				}
+			}
+		}
+	}
+
+	ret.GamesStarted = len(started)
+	if ret.GamesStarted > 0 {
+		ret.AverageCompletion = completion / ret.GamesStarted
+	}
+
+	for _, name := range ScorerNames() {
+		total := ScoreTotal{Scorer: name}
+		for _, game := range started {
+			for _, achievement := range game.Achievements.Achievements {
+				if achievement.Achieved {
+					total.Points += scorers[name].Points(achievement.GlobalPercentage)
+				}
+			}
+		}
+		ret.Scores = append(ret.Scores, total)
+	}
+
+	for i := statsMonths - 1; i >= 0; i-- {
+		month := thisMonth.AddDate(0, -i, 0)
+		ret.UnlocksPerMonth = append(ret.UnlocksPerMonth, MonthUnlocks{Month: month, Count: months[month]})
+	}
+
+	ret.Genres, ret.GenresIncomplete, err = d.genreCompletion(ctx, started)
+	if err != nil {
+		return UserStats{}, err
+	}
+
+	return ret, nil
+}
+
+// genreCompletion totals the achievements unlocked per store genre. Games that
+// have no store details are left out. The totals are reported incomplete when
+// the details of some games could not be loaded at all.
+func (d *Data) genreCompletion(ctx context.Context, games []GameAchievements) ([]GenreCompletion, bool, error) {
+	genres := map[string]*GenreCompletion{}
+	incomplete := false
+	mx := sync.Mutex{}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(achievementsConcurrency)
+	for _, game := range games {
+		g.Go(func() error {
+			details, err := d.steam.GetAppDetails(ctx, game.Game.ID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				} else if errors.Is(err, steam.ErrNotFound) {
+					slog.Debug("No store details for game. Leaving out of genres.", "game-id", game.Game.ID, "error", err)
+					return nil
+				}
+
+				slog.Debug("Unable to load store details for game. Leaving out of genres for now.", "game-id", game.Game.ID, "error", err)
+				mx.Lock()
+				defer mx.Unlock()
+				incomplete = true
+				return nil
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+			for _, genre := range details.Genres {
+				total, ok := genres[genre.Description]
+				if !ok {
+					total = &GenreCompletion{Genre: genre.Description}
+					genres[genre.Description] = total
+				}
+
+				total.Games++
+				total.Unlocked += game.Achievements.AchievementUnlockedCount
+				total.Total += game.Achievements.AchievementTotalCount
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, false, err
+	}
+
+	ret := []GenreCompletion{}
+	for _, genre := range genres {
+		genre.Percentage = int(float64(genre.Unlocked) / float64(genre.Total) * 100)
+		ret = append(ret, *genre)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Games != ret[j].Games {
+			return ret[i].Games > ret[j].Games
+		}
+		return ret[i].Genre < ret[j].Genre
+	})
+
+	return ret, incomplete, nil
+}
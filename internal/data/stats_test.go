@@ -0,0 +1,46 @@
+package data
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUserStatsRateLimitedStore(t *testing.T) {
+	f := newFakeSteam()
+	now := time.Now()
+	for i := range 3 {
+		f.users["1"] = append(f.users["1"], fakeGame{
+			ID:           uint64(i + 1),
+			Name:         "Game",
+			Achievements: 4,
+			Unlocked:     2,
+			UnlockedAt:   now.Add(-time.Hour),
+			LastPlayed:   now.Add(-time.Hour),
+		})
+	}
+	f.storeStatus = http.StatusTooManyRequests
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	stats, err := d.computeUserStats(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stats.GenresIncomplete || !stats.Incomplete() {
+		t.Error("got complete genres, want them incomplete while rate limited")
+	}
+	if stats.GamesStarted != 3 {
+		t.Errorf("got %d games started, want 3", stats.GamesStarted)
+	}
+
+	// The store is not asked again until it is ready
+	requests := f.count("appdetails")
+	if _, err := d.computeUserStats(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.count("appdetails"); got != requests {
+		t.Errorf("got %d store requests, want %d while backing off", got, requests)
+	}
+}
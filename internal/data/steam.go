@@ -3,7 +3,6 @@ package data
 import (
 	"context"
 	"fmt"
-	"regexp"
 	"strconv"
 	"time"
 
@@ -14,6 +13,9 @@ import (
 type SteamHelper struct {
 	client *steam.Client
 	cache  cache.Cache
+	// onPlayerAchievements is called whenever a player's achievements are
+	// fetched from Steam, rather than read from the cache.
+	onPlayerAchievements func(ctx context.Context, userID string, appID uint64, achievements *steam.PlayerAchievements)
 }
 
 func NewSteamHelper(client *steam.Client, cache cache.Cache) *SteamHelper {
@@ -23,38 +25,100 @@ func NewSteamHelper(client *steam.Client, cache cache.Cache) *SteamHelper {
 	}
 }
 
+// negativeTTLs define how long each kind of "no data" response from Steam is
+// remembered before Steam is asked again. They are deliberately short, as users
+// may change their privacy settings at any time. Any other error is considered
+// transient and is never cached.
+var negativeTTLs = map[error]time.Duration{
+	steam.ErrPrivateProfile: time.Minute * 15,
+	steam.ErrNoStats:        time.Hour * 24,
+	steam.ErrNotFound:       time.Hour,
+}
+
+// How long each Steam response is cached for, and how long past that it is
+// still served while it is refreshed or while Steam is unavailable. Responses
+// that change often are served stale for less time.
+const (
+	ttlGameGlobal           = time.Hour * 24
+	staleGameGlobal         = time.Hour * 24 * 6
+	ttlGameSchema           = time.Hour * 24 * 7
+	staleGameSchema         = time.Hour * 24 * 23
+	ttlGameDetails          = time.Hour * 24 * 7
+	staleGameDetails        = time.Hour * 24 * 7
+	ttlPlayerSummary        = time.Hour
+	stalePlayerSummary      = time.Hour * 23
+	ttlPlayerAchievements   = time.Hour
+	stalePlayerAchievements = time.Hour * (24*7 - 1)
+	ttlPlayerGames          = time.Hour * 24
+	stalePlayerGames        = time.Hour * 24 * 6
+	ttlPlayerVanity         = time.Hour * 24
+	stalePlayerVanity       = time.Hour * 24 * 6
+)
+
+// loadOptions apply to a Steam response cached by the SteamHelper, keeping it
+// for staleFor past its TTL.
+func loadOptions(staleFor time.Duration) []cache.LoadOption {
+	return []cache.LoadOption{
+		cache.StaleFor(staleFor),
+		cache.CacheErrors(negativeTTLs),
+	}
+}
+
 func (c *SteamHelper) GetGlobalAchievementPercentagesForApp(ctx context.Context, appID uint64) (*steam.GlobalAchievementPercentages, error) {
-	// Check the cache to see if we've already scraped
-	key := fmt.Sprintf("game:%d:global", appID)
-	ret := &steam.GlobalAchievementPercentages{}
-	if err := c.cache.Get(ctx, key, ret); err == nil {
-		return ret, nil
+	key := keyGameGlobal.Key(appID)
+	return cache.GetOrLoad(ctx, c.cache, key, ttlGameGlobal, c.loadGlobalAchievementPercentagesForApp(appID), loadOptions(staleGameGlobal)...)
+}
+
+func (c *SteamHelper) RefreshGlobalAchievementPercentagesForApp(ctx context.Context, appID uint64) (*steam.GlobalAchievementPercentages, error) {
+	key := keyGameGlobal.Key(appID)
+	return cache.Refresh(ctx, c.cache, key, ttlGameGlobal, c.loadGlobalAchievementPercentagesForApp(appID), loadOptions(staleGameGlobal)...)
+}
+
+func (c *SteamHelper) loadGlobalAchievementPercentagesForApp(appID uint64) cache.Loader[*steam.GlobalAchievementPercentages] {
+	return func(ctx context.Context) (*steam.GlobalAchievementPercentages, error) {
+		return c.client.ISteamUserStats.GetGlobalAchievementPercentagesForApp(ctx, appID)
 	}
+}
 
-	// Nope! Build the cache
-	ret, err := c.client.ISteamUserStats.GetGlobalAchievementPercentagesForApp(ctx, appID)
+func (c *SteamHelper) GetAppDetails(ctx context.Context, appID uint64) (*steam.AppDetails, error) {
+	key := keyGameDetails.Key(appID)
+	return cache.GetOrLoad(ctx, c.cache, key, ttlGameDetails, func(ctx context.Context) (*steam.AppDetails, error) {
+		return c.client.Store.GetAppDetails(ctx, appID)
+	}, loadOptions(staleGameDetails)...)
+}
+
+func (c *SteamHelper) GetSchemasInCache(ctx context.Context) ([]uint64, error) {
+	ret, err := c.appIDsInCache(ctx, keyGameSchema)
 	if err != nil {
-		return nil, err
+		return ret, fmt.Errorf("unable to scan cache for game schemas: %w", err)
 	}
+	return ret, nil
+}
 
-	return ret, c.cache.Set(ctx, key, ret, time.Hour*24)
+func (c *SteamHelper) GetGlobalsInCache(ctx context.Context) ([]uint64, error) {
+	ret, err := c.appIDsInCache(ctx, keyGameGlobal)
+	if err != nil {
+		return ret, fmt.Errorf("unable to scan cache for global achievement percentages: %w", err)
+	}
+	return ret, nil
 }
 
-func (c *SteamHelper) GetSchemasInCache(ctx context.Context) ([]uint64, error) {
-	gameSchemas, err := c.cache.Keys(ctx, "game:*:schema")
+// appIDsInCache lists the app IDs of every key cached in a family keyed by app
+// ID.
+func (c *SteamHelper) appIDsInCache(ctx context.Context, family cache.KeyFamily) ([]uint64, error) {
+	keys, err := c.cache.Keys(ctx, family.Pattern())
 	if err != nil {
-		return []uint64{}, fmt.Errorf("unable to scan cache for game schemas: %w", err)
+		return []uint64{}, err
 	}
 
-	r := regexp.MustCompile(`^game:(\d+):schema$`)
 	ret := []uint64{}
-	for _, key := range gameSchemas {
-		match := r.FindStringSubmatch(key)
-		if match == nil || len(match) < 2 {
-			return ret, fmt.Errorf("unable to match returned key against regex")
+	for _, key := range keys {
+		match, err := family.Parse(key)
+		if err != nil {
+			return ret, err
 		}
 
-		matchInt, err := strconv.ParseUint(match[1], 10, 64)
+		matchInt, err := strconv.ParseUint(match[0], 10, 64)
 		if err != nil {
 			return ret, fmt.Errorf("returned match %q was not a valid integer: %w", match, err)
 		}
@@ -66,90 +130,67 @@ func (c *SteamHelper) GetSchemasInCache(ctx context.Context) ([]uint64, error) {
 }
 
 func (c *SteamHelper) GetSchemaForGame(ctx context.Context, appID uint64) (*steam.GameSchema, error) {
-	// Check the cache to see if we've already scraped
-	key := fmt.Sprintf("game:%d:schema", appID)
-	ret := &steam.GameSchema{}
-	if err := c.cache.Get(ctx, key, ret); err == nil {
-		return ret, nil
-	}
-
-	// Nope! Build the cache
-	ret, err := c.client.ISteamUserStats.GetSchemaForGame(ctx, appID)
-	if err != nil {
-		return nil, err
-	}
-
-	return ret, c.cache.Set(ctx, key, ret, time.Hour*24*7)
+	key := keyGameSchema.Key(appID)
+	return cache.GetOrLoad(ctx, c.cache, key, ttlGameSchema, c.loadSchemaForGame(appID), loadOptions(staleGameSchema)...)
 }
 
-func (c *SteamHelper) GetPlayerSummaries(ctx context.Context, userID string) (*steam.PlayerSummaries, error) {
-	// Check the cache to see if we've already scraped
-	key := fmt.Sprintf("player:%s:summary", userID)
-	ret := &steam.PlayerSummaries{}
-	if err := c.cache.Get(ctx, key, ret); err == nil {
-		return ret, nil
-	}
+func (c *SteamHelper) RefreshSchemaForGame(ctx context.Context, appID uint64) (*steam.GameSchema, error) {
+	key := keyGameSchema.Key(appID)
+	return cache.Refresh(ctx, c.cache, key, ttlGameSchema, c.loadSchemaForGame(appID), loadOptions(staleGameSchema)...)
+}
 
-	// Nope! Build the cache
-	ret, err := c.client.ISteamUser.GetPlayerSummaries(ctx, userID)
-	if err != nil {
-		return nil, err
+func (c *SteamHelper) loadSchemaForGame(appID uint64) cache.Loader[*steam.GameSchema] {
+	return func(ctx context.Context) (*steam.GameSchema, error) {
+		return c.client.ISteamUserStats.GetSchemaForGame(ctx, appID)
 	}
+}
 
-	return ret, c.cache.Set(ctx, key, ret, time.Hour)
+func (c *SteamHelper) GetPlayerSummaries(ctx context.Context, userID string) (*steam.PlayerSummaries, error) {
+	key := keyPlayerSummary.Key(userID)
+	return cache.GetOrLoad(ctx, c.cache, key, ttlPlayerSummary, func(ctx context.Context) (*steam.PlayerSummaries, error) {
+		return c.client.ISteamUser.GetPlayerSummaries(ctx, userID)
+	}, loadOptions(stalePlayerSummary)...)
 }
 
 func (c *SteamHelper) GetPlayerAchievements(ctx context.Context, userID string, appID uint64) (*steam.PlayerAchievements, error) {
-	// Check the cache to see if we've already scraped
-	key := fmt.Sprintf("player:%s:game:%d:achievements", userID, appID)
-	ret := &steam.PlayerAchievements{}
-	if err := c.cache.Get(ctx, key, ret); err == nil {
-		return ret, nil
-	}
+	key := keyPlayerAchievements.Key(userID, appID)
+	return cache.GetOrLoad(ctx, c.cache, key, ttlPlayerAchievements, c.loadPlayerAchievements(userID, appID), loadOptions(stalePlayerAchievements)...)
+}
 
-	// Nope! Build the cache
-	ret, err := c.client.ISteamUserStats.GetPlayerAchievements(ctx, userID, appID)
-	if err != nil {
-		// This will issue a Bad Request if no achievements exist for it
-		// For now, emit an empty result so that we can cache the zero value
-		ret = &steam.PlayerAchievements{
-			PlayerStats: steam.PlayerStats{},
+func (c *SteamHelper) RefreshPlayerAchievements(ctx context.Context, userID string, appID uint64) (*steam.PlayerAchievements, error) {
+	key := keyPlayerAchievements.Key(userID, appID)
+	return cache.Refresh(ctx, c.cache, key, ttlPlayerAchievements, c.loadPlayerAchievements(userID, appID), loadOptions(stalePlayerAchievements)...)
+}
+
+func (c *SteamHelper) loadPlayerAchievements(userID string, appID uint64) cache.Loader[*steam.PlayerAchievements] {
+	return func(ctx context.Context) (*steam.PlayerAchievements, error) {
+		ret, err := c.client.ISteamUserStats.GetPlayerAchievements(ctx, userID, appID)
+		if err == nil && c.onPlayerAchievements != nil {
+			c.onPlayerAchievements(ctx, userID, appID, ret)
 		}
+		return ret, err
 	}
-
-	return ret, c.cache.Set(ctx, key, ret, time.Hour)
 }
 
 func (c *SteamHelper) GetPlayerOwnedGames(ctx context.Context, userID string) (*steam.OwnedGames, error) {
-	// Check the cache to see if we've already scraped
-	key := fmt.Sprintf("player:%s:games", userID)
-	ret := &steam.OwnedGames{}
-	if err := c.cache.Get(ctx, key, ret); err == nil {
-		return ret, nil
-	}
-
-	// Nope! Build the cache
-	ret, err := c.client.IPlayerService.GetOwnedGames(ctx, userID)
-	if err != nil {
-		return nil, err
-	}
-
-	return ret, c.cache.Set(ctx, key, ret, time.Hour*24)
+	key := keyPlayerGames.Key(userID)
+	return cache.GetOrLoad(ctx, c.cache, key, ttlPlayerGames, c.loadPlayerOwnedGames(userID), loadOptions(stalePlayerGames)...)
 }
 
-func (c *SteamHelper) ResolveVanityURL(ctx context.Context, vanityURL string) (*steam.VanityURLResponse, error) {
-	// Check the cache to see if we've already scraped
-	key := fmt.Sprintf("player:%s:vanity", vanityURL)
-	ret := &steam.VanityURLResponse{}
-	if err := c.cache.Get(ctx, key, ret); err == nil {
-		return ret, nil
-	}
+func (c *SteamHelper) RefreshPlayerOwnedGames(ctx context.Context, userID string) (*steam.OwnedGames, error) {
+	key := keyPlayerGames.Key(userID)
+	return cache.Refresh(ctx, c.cache, key, ttlPlayerGames, c.loadPlayerOwnedGames(userID), loadOptions(stalePlayerGames)...)
+}
 
-	// Nope! Build the cache
-	ret, err := c.client.ISteamUser.ResolveVanityURL(ctx, vanityURL)
-	if err != nil {
-		return nil, err
+func (c *SteamHelper) loadPlayerOwnedGames(userID string) cache.Loader[*steam.OwnedGames] {
+	return func(ctx context.Context) (*steam.OwnedGames, error) {
+		return c.client.IPlayerService.GetOwnedGames(ctx, userID)
 	}
+}
 
-	return ret, c.cache.Set(ctx, key, ret, time.Hour*24)
+func (c *SteamHelper) ResolveVanityURL(ctx context.Context, vanityURL string) (*steam.VanityURLResponse, error) {
+	key := keyPlayerVanity.Key(vanityURL)
+	return cache.GetOrLoad(ctx, c.cache, key, ttlPlayerVanity, func(ctx context.Context) (*steam.VanityURLResponse, error) {
+		return c.client.ISteamUser.ResolveVanityURL(ctx, vanityURL)
+	}, loadOptions(stalePlayerVanity)...)
 }
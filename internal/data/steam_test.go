@@ -0,0 +1,159 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/taiidani/achievements/internal/steam"
+)
+
+func TestSteamHelperCoalesces(t *testing.T) {
+	f := newFakeSteam()
+	f.users["1"] = []fakeGame{
+		{ID: 1, Name: "One", Achievements: 2, LastPlayed: time.Now()},
+		{ID: 2, Name: "Two", Achievements: 2, LastPlayed: time.Now()},
+	}
+	f.delay = time.Millisecond * 50
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	wg := sync.WaitGroup{}
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			appID := uint64(i%2 + 1)
+			schema, err := d.steam.GetSchemaForGame(ctx, appID)
+			if err != nil {
+				t.Error(err)
+			} else if len(schema.Game.AvailableGameStats.Achievements) != 2 {
+				t.Errorf("got %d achievements for %d, want 2", len(schema.Game.AvailableGameStats.Achievements), appID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Each key is only fetched once, however many callers missed it
+	if got := f.count("GetSchemaForGame"); got != 2 {
+		t.Errorf("got %d schema requests, want 2", got)
+	}
+}
+
+func TestSteamHelperServesStale(t *testing.T) {
+	f := newFakeSteam()
+	f.users["1"] = []fakeGame{{ID: 1, Name: "One", Achievements: 2, LastPlayed: time.Now()}}
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	games, err := d.GetGames(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(games) != 1 || games[0].Stale {
+		t.Fatalf("got %+v, want one fresh game", games)
+	}
+
+	// Age the cached games past their TTL, as stored by cache.GetOrLoad
+	key := keyPlayerGames.Key("1")
+	cached := struct {
+		Value     json.RawMessage
+		FetchedAt time.Time
+		StaleAt   time.Time
+	}{}
+	if err := d.cache.Get(ctx, key, &cached); err != nil {
+		t.Fatal(err)
+	}
+	cached.FetchedAt = time.Now().Add(-ttlPlayerGames - time.Hour)
+	cached.StaleAt = time.Now().Add(-time.Hour)
+	if err := d.cache.Set(ctx, key, cached, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	f.mx.Lock()
+	f.users["1"] = append(f.users["1"], fakeGame{ID: 2, Name: "Two", Achievements: 2, LastPlayed: time.Now()})
+	f.mx.Unlock()
+
+	// The stale games are served at once while they are refreshed
+	games, err = d.GetGames(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(games) != 1 || !games[0].Stale || !games[0].AsOf.Equal(cached.FetchedAt) {
+		t.Fatalf("got %+v, want the one stale game as of %s", games, cached.FetchedAt)
+	}
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		games, err = d.GetGames(ctx, "1")
+		if err != nil {
+			t.Fatal(err)
+		} else if len(games) == 2 && !games[0].Stale {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("got %+v, want both games once refreshed", games)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if got := f.count("GetOwnedGames"); got != 2 {
+		t.Errorf("got %d owned games requests, want 2", got)
+	}
+}
+
+func TestSteamHelperCachesErrors(t *testing.T) {
+	f := newFakeSteam()
+	f.users["1"] = []fakeGame{{ID: 1, Name: "One", Achievements: 2, LastPlayed: time.Now()}}
+	f.users["2"] = []fakeGame{{ID: 2, Name: "Two", Achievements: 2, LastPlayed: time.Now()}}
+	f.status[3] = http.StatusNotFound
+	f.status[4] = http.StatusInternalServerError
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		load     func() error
+		endpoint string
+		want     error
+		// wantRequests is the number of requests made after loading twice.
+		wantRequests int
+	}{
+		{
+			name:         "no stats",
+			load:         func() error { _, err := d.steam.GetPlayerAchievements(ctx, "1", 2); return err },
+			endpoint:     "GetPlayerAchievements",
+			want:         steam.ErrNoStats,
+			wantRequests: 1,
+		},
+		{
+			name:         "not found",
+			load:         func() error { _, err := d.steam.GetSchemaForGame(ctx, 3); return err },
+			endpoint:     "GetSchemaForGame",
+			want:         steam.ErrNotFound,
+			wantRequests: 1,
+		},
+		{
+			name:         "transient",
+			load:         func() error { _, err := d.steam.GetGlobalAchievementPercentagesForApp(ctx, 4); return err },
+			endpoint:     "GetGlobalAchievementPercentagesForApp",
+			wantRequests: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 2 {
+				err := tt.load()
+				if err == nil {
+					t.Fatal("got no error, want one")
+				} else if tt.want != nil && !errors.Is(err, tt.want) {
+					t.Errorf("got %v, want %v", err, tt.want)
+				}
+			}
+
+			if got := f.count(tt.endpoint); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
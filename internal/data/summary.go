@@ -0,0 +1,353 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+)
+
+// Summary records a user's progress in every game they have played, so that
+// it can be listed without loading each game's achievements.
+type Summary struct {
+	SteamID string
+	// Games are keyed by app ID. Games whose achievements could not be loaded
+	// are missing.
+	Games map[uint64]GameProgress
+	// UnlockThreshold is the highest unlock total milestone reached, so that
+	// it is not reached again should games drop out of the summary.
+	UnlockThreshold int
+	UpdatedAt       time.Time
+}
+
+// Points totals the score of every game in the summary.
+func (s Summary) Points() int {
+	ret := 0
+	for _, game := range s.Games {
+		ret += game.Points
+	}
+	return ret
+}
+
+// GameProgress summarizes a user's achievements in a single game.
+type GameProgress struct {
+	Unlocked   int
+	Total      int
+	Percentage int
+	// Points is the score of the unlocked achievements.
+	Points int
+	// LastUnlock is the most recent unlock with a known time, if any.
+	LastUnlock time.Time
+	// Rarest is the unlocked achievement with the lowest global unlock
+	// percentage, if any.
+	Rarest   *Achievement
+	Estimate *CompletionEstimate
+	// UpdatedAt is when the progress was computed.
+	UpdatedAt time.Time
+}
+
+// Progress summarizes the achievements.
+func (a Achievements) Progress() GameProgress {
+	ret := GameProgress{
+		Unlocked:   a.AchievementUnlockedCount,
+		Total:      a.AchievementTotalCount,
+		Percentage: a.AchievementUnlockedPercentage,
+		Points:     a.Points,
+		Estimate:   a.Estimate,
+		UpdatedAt:  time.Now(),
+	}
+
+	for _, achievement := range a.Achievements {
+		if achievement.UnlockedOn != nil && achievement.UnlockedOn.After(ret.LastUnlock) {
+			ret.LastUnlock = *achievement.UnlockedOn
+		}
+
+		// Achievements without a global percentage are missing from Steam's
+		// statistics, rather than truly the rarest
+		if achievement.Achieved && achievement.GlobalPercentage > 0 && (ret.Rarest == nil || achievement.GlobalPercentage < ret.Rarest.GlobalPercentage) {
+			ret.Rarest = &achievement
+		}
+	}
+
+	return ret
+}
+
+// summaryMaxAge is how long a game's progress is kept before it is computed
+// again, even if the game has not been played since.
+const summaryMaxAge = time.Hour * 24
+
+// summaryTTL is how long a summary is kept in the cache without being read.
+const summaryTTL = time.Hour * 24 * 30
+
+// summaryUpdateLease is how long a background update of a summary is assumed
+// to take at most, after which another may be started in case it was lost.
+const summaryUpdateLease = time.Minute * 10
+
+// summaries coalesces concurrent updates of the same user's summary.
+var summaries singleflight.Group
+
+// GetSummary returns the user's progress in every game they have played.
+// Concurrent calls for the same user share a single update.
+//
+// The summary is updated incrementally: only games that are new, have been
+// played since their progress was computed, or whose progress is older than a
+// day have their achievements loaded again.
+func (d *Data) GetSummary(ctx context.Context, userID string) (Summary, error) {
+	key := keyPlayerProgress.Key(userID)
+	ret, err, _ := summaries.Do(key, func() (any, error) {
+		return d.updateSummary(context.WithoutCancel(ctx), userID, false, nil)
+	})
+	if err != nil {
+		return Summary{}, err
+	}
+
+	return ret.(Summary), nil
+}
+
+// GetCachedSummary returns the user's progress as it was last stored, without
+// waiting for it to be updated. Games that are missing from it or out of date
+// are updated in the background, for a later call to return.
+func (d *Data) GetCachedSummary(ctx context.Context, userID string) (Summary, error) {
+	ret := Summary{}
+	if err := d.cache.Get(ctx, keyPlayerProgress.Key(userID), &ret); err != nil && !errors.Is(err, cache.ErrNotFound) {
+		slog.Warn("Unable to read summary. Updating it in the background.", "steam-id", userID, "error", err)
+	}
+
+	games, err := d.GetGames(ctx, userID)
+	if err != nil {
+		return Summary{}, fmt.Errorf("could not summarize %q: %w", userID, err)
+	}
+
+	for _, game := range games {
+		if ret.outdated(game) {
+			d.updateSummaryInBackground(ctx, userID)
+			break
+		}
+	}
+
+	return ret, nil
+}
+
+// updateSummaryInBackground starts updating the user's summary, through a job
+// if they are enabled. Only one update is started at a time across every
+// replica.
+func (d *Data) updateSummaryInBackground(ctx context.Context, userID string) {
+	log := slog.With("steam-id", userID)
+	ctx = context.WithoutCancel(ctx)
+	key := keyPlayerProgressUpdate.Key(userID)
+
+	ok, err := d.cache.SetNX(ctx, key, time.Now(), summaryUpdateLease)
+	if err != nil {
+		log.Warn("Unable to schedule summary update", "error", err)
+		return
+	} else if !ok {
+		return
+	}
+
+	if d.jobs != nil {
+		_, err := d.jobs.Enqueue(ctx, JobUpdateSummary, map[string]string{"steam-id": userID})
+		if err == nil {
+			return
+		}
+		log.Warn("Unable to queue summary update. Updating it here.", "error", err)
+	}
+
+	go func() {
+		if _, err := d.updateQueuedSummary(ctx, userID); err != nil {
+			log.Warn("Unable to update summary", "error", err)
+		}
+	}()
+}
+
+// updateQueuedSummary updates the user's summary for updateSummaryInBackground,
+// allowing another update to be started once it is done.
+func (d *Data) updateQueuedSummary(ctx context.Context, userID string) (Summary, error) {
+	defer func() {
+		if err := d.cache.Delete(context.WithoutCancel(ctx), keyPlayerProgressUpdate.Key(userID)); err != nil {
+			slog.Warn("Unable to clear summary update", "steam-id", userID, "error", err)
+		}
+	}()
+
+	return d.GetSummary(ctx, userID)
+}
+
+// RefreshSummary recomputes the user's progress in every game they have
+// played from freshly fetched Steam data, regardless of its age. The optional
+// progress function is called as each game is summarized.
+func (d *Data) RefreshSummary(ctx context.Context, userID string, progress func(done, total int)) (Summary, error) {
+	key := keyPlayerProgress.Key(userID)
+	ret, err, _ := summaries.Do(key+":refresh", func() (any, error) {
+		return d.updateSummary(context.WithoutCancel(ctx), userID, true, progress)
+	})
+	if err != nil {
+		return Summary{}, err
+	}
+
+	return ret.(Summary), nil
+}
+
+func (d *Data) updateSummary(ctx context.Context, userID string, force bool, progress func(done, total int)) (Summary, error) {
+	log := slog.With("steam-id", userID)
+	key := keyPlayerProgress.Key(userID)
+
+	ret := Summary{}
+	if err := d.cache.Get(ctx, key, &ret); err != nil && !errors.Is(err, cache.ErrNotFound) {
+		log.Warn("Unable to read summary. Computing it again.", "error", err)
+	}
+	if ret.Games == nil {
+		ret.Games = map[uint64]GameProgress{}
+	}
+	ret.SteamID = userID
+
+	if force {
+		if _, err := d.steam.RefreshPlayerOwnedGames(ctx, userID); err != nil {
+			log.Warn("Unable to refresh owned games. Using cached games.", "error", err)
+		}
+	}
+
+	games, err := d.GetGames(ctx, userID)
+	if err != nil {
+		return Summary{}, fmt.Errorf("could not summarize %q: %w", userID, err)
+	}
+
+	// Forget games that are no longer listed
+	listed := map[uint64]bool{}
+	for _, game := range games {
+		listed[game.ID] = true
+	}
+	changed := false
+	for id := range ret.Games {
+		if !listed[id] {
+			delete(ret.Games, id)
+			changed = true
+		}
+	}
+
+	// Only games that are out of date need their achievements loaded. They
+	// are all found before any are loaded, as ret.Games is written to by the
+	// loads.
+	stale := []Game{}
+	for _, game := range games {
+		if force || ret.outdated(game) {
+			stale = append(stale, game)
+		}
+	}
+
+	done := 0
+	mx := sync.Mutex{}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(achievementsConcurrency)
+	for _, game := range stale {
+		g.Go(func() error {
+			defer func() {
+				mx.Lock()
+				defer mx.Unlock()
+				done++
+				if progress != nil {
+					progress(done, len(stale))
+				}
+			}()
+
+			// Errors are left for GetAchievements to handle, which falls
+			// back upon any cached achievements
+			if force {
+				_, _ = d.steam.RefreshPlayerAchievements(gctx, userID, game.ID)
+			}
+
+			achievements, err := d.GetAchievements(gctx, userID, game.ID)
+			if err != nil {
+				if gctx.Err() != nil {
+					return gctx.Err()
+				}
+
+				log.Warn("Unable to summarize game. Keeping previous progress.", "game-id", game.ID, "error", err)
+				return nil
+			}
+
+			mx.Lock()
+			defer mx.Unlock()
+			ret.Games[game.ID] = achievements.Progress()
+			changed = true
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return Summary{}, fmt.Errorf("could not summarize %q: %w", userID, err)
+	}
+
+	if changed {
+		byID := map[uint64]Game{}
+		for _, game := range games {
+			byID[game.ID] = game
+		}
+		return d.storeSummary(ctx, ret, byID)
+	}
+
+	return ret, nil
+}
+
+// storeSummary saves an updated summary, publishing the milestones reached
+// since the summary that was stored before it.
+//
+// Summaries are updated concurrently by every replica, so they are stored
+// under a lock. Progress stored by another update since this one began is
+// merged in, and milestones are found against what was stored last, so that
+// each milestone is only published by the update that first reaches it.
+func (d *Data) storeSummary(ctx context.Context, summary Summary, games map[uint64]Game) (Summary, error) {
+	log := slog.With("steam-id", summary.SteamID)
+	key := keyPlayerProgress.Key(summary.SteamID)
+
+	unlock, err := d.lock(ctx, key)
+	if err != nil {
+		log.Warn("Unable to store summary", "error", err)
+		return summary, nil
+	}
+	defer unlock()
+
+	stored := Summary{}
+	if err := d.cache.Get(ctx, key, &stored); err != nil && !errors.Is(err, cache.ErrNotFound) {
+		log.Warn("Unable to read summary. Replacing it.", "error", err)
+	}
+
+	for id, progress := range stored.Games {
+		if current, ok := summary.Games[id]; ok && current.UpdatedAt.Before(progress.UpdatedAt) {
+			summary.Games[id] = progress
+		}
+	}
+	summary.UpdatedAt = time.Now()
+
+	// Without a previous summary there is no telling which of the user's
+	// progress is new
+	milestones := []Milestone{}
+	if !stored.UpdatedAt.IsZero() {
+		milestones = detectMilestones(summary.SteamID, stored.Games, summary.Games, games, stored.UnlockThreshold, summary.UpdatedAt)
+	}
+	summary.UnlockThreshold = max(stored.UnlockThreshold, summary.UnlockThreshold, reachedThreshold(unlockedTotal(summary.Games)))
+
+	if err := d.cache.Set(ctx, key, summary, summaryTTL); err != nil {
+		log.Warn("Unable to store summary", "error", err)
+		return summary, nil
+	}
+
+	for _, milestone := range milestones {
+		log.Info("Milestone reached", "kind", milestone.Kind(), "description", milestone.Description())
+		d.events.Publish(ctx, milestone)
+	}
+
+	return summary, nil
+}
+
+// outdated reports whether the game's progress is missing from the summary, or
+// needs to be computed again as the game has been played since or the progress
+// has aged out.
+func (s Summary) outdated(game Game) bool {
+	previous, ok := s.Games[game.ID]
+	return !ok || !game.LastPlayed.Before(previous.UpdatedAt) || time.Since(previous.UpdatedAt) >= summaryMaxAge
+}
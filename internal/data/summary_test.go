@@ -0,0 +1,183 @@
+package data
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/taiidani/achievements/internal/steam"
+)
+
+// TestGetSummaryUpdatesStaleGamesConcurrently guards against reading the
+// summary's games while they are being written, which -race reports.
+func TestGetSummaryUpdatesStaleGamesConcurrently(t *testing.T) {
+	f := newFakeSteam()
+	now := time.Now()
+	for i := range 40 {
+		f.users["1"] = append(f.users["1"], fakeGame{
+			ID:           uint64(i + 1),
+			Name:         "Game",
+			Achievements: 4,
+			Unlocked:     i % 5,
+			UnlockedAt:   now.Add(-time.Hour),
+			LastPlayed:   now.Add(-time.Hour),
+		})
+	}
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	// Summarize half of the games in the past, so that the update both keeps
+	// and replaces progress
+	previous := Summary{SteamID: "1", Games: map[uint64]GameProgress{}, UpdatedAt: now.Add(-time.Minute)}
+	for i := range 40 {
+		updatedAt := now
+		if i%2 == 0 {
+			updatedAt = now.Add(-2 * summaryMaxAge)
+		}
+		previous.Games[uint64(i+1)] = GameProgress{Total: 4, UpdatedAt: updatedAt}
+	}
+	if err := d.cache.Set(ctx, keyPlayerProgress.Key("1"), previous, summaryTTL); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := d.GetSummary(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(summary.Games) != 40 {
+		t.Fatalf("got %d games, want 40", len(summary.Games))
+	}
+	for i := range 40 {
+		got := summary.Games[uint64(i+1)].Unlocked
+		want := i % 5
+		if i%2 != 0 {
+			// Fresh progress is kept as it was
+			want = 0
+		}
+		if got != want {
+			t.Errorf("game %d: got %d unlocked, want %d", i+1, got, want)
+		}
+	}
+	if got := f.count("GetPlayerAchievements"); got != 20 {
+		t.Errorf("got %d achievement requests, want 20 for the stale games", got)
+	}
+}
+
+func TestGetCachedSummaryUpdatesInBackground(t *testing.T) {
+	f := newFakeSteam()
+	now := time.Now()
+	f.users["1"] = []fakeGame{
+		{ID: 1, Name: "Game", Achievements: 4, Unlocked: 2, UnlockedAt: now.Add(-time.Hour), LastPlayed: now.Add(-time.Hour)},
+	}
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	// A cold summary is returned empty rather than waited for
+	summary, err := d.GetCachedSummary(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Games) != 0 {
+		t.Fatalf("got %d games, want none before the update", len(summary.Games))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		summary, err = d.GetCachedSummary(ctx, "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(summary.Games) == 1 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatal("summary was not updated in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := summary.Games[1].Unlocked; got != 2 {
+		t.Errorf("got %d unlocked, want 2", got)
+	}
+	if got := f.count("GetPlayerAchievements"); got != 1 {
+		t.Errorf("got %d achievement requests, want 1 for a single update", got)
+	}
+}
+
+func TestSummaryPoints(t *testing.T) {
+	f := newFakeSteam()
+	now := time.Now()
+	for i := range 3 {
+		f.users["1"] = append(f.users["1"], fakeGame{
+			ID:           uint64(i + 1),
+			Name:         "Game",
+			Achievements: 4,
+			Unlocked:     i + 1,
+			UnlockedAt:   now.Add(-time.Hour),
+			LastPlayed:   now.Add(-time.Hour),
+		})
+	}
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	summary, err := d.GetSummary(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := 0
+	for i := range 3 {
+		achievements, err := d.GetAchievements(ctx, "1", uint64(i+1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want += achievements.Points
+	}
+	if want == 0 {
+		t.Fatal("got no points for the unlocked achievements")
+	}
+	if got := summary.Points(); got != want {
+		t.Errorf("got %d points, want %d", got, want)
+	}
+}
+
+// TestSummaryMilestonesPublishedOnce guards against replicas updating the same
+// summary concurrently each publishing the milestones reached.
+func TestSummaryMilestonesPublishedOnce(t *testing.T) {
+	f := newFakeSteam()
+	now := time.Now()
+	f.users["1"] = []fakeGame{
+		{ID: 1, Name: "Game", Achievements: 2, Unlocked: 2, UnlockedAt: now.Add(-time.Hour), LastPlayed: now.Add(-time.Hour)},
+	}
+	d := newTestData(t, f)
+	replica := NewData(steam.NewClient(), d.cache, nil)
+	ctx := context.Background()
+
+	previous := Summary{SteamID: "1", Games: map[uint64]GameProgress{1: {Total: 2, UpdatedAt: now.Add(-2 * summaryMaxAge)}}, UpdatedAt: now.Add(-time.Minute)}
+	if err := d.cache.Set(ctx, keyPlayerProgress.Key("1"), previous, summaryTTL); err != nil {
+		t.Fatal(err)
+	}
+
+	var published atomic.Int32
+	for _, backend := range []*Data{d, replica} {
+		SubscribeTo(backend.Events(), func(context.Context, PerfectGame) { published.Add(1) })
+	}
+
+	wg := sync.WaitGroup{}
+	for _, backend := range []*Data{d, replica, d, replica} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := backend.updateSummary(ctx, "1", true, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := published.Load(); got != 1 {
+		t.Errorf("got %d perfect game milestones, want 1", got)
+	}
+}
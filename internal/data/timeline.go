@@ -0,0 +1,88 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+)
+
+// Unlock is a single achievement unlocked by a user, alongside the game that it
+// belongs to.
+type Unlock struct {
+	Game        Game
+	Name        string
+	Description string
+	Icon        string
+	UnlockedOn  time.Time
+}
+
+// timelineTTL is how long a user's timeline is cached for, so that it is not
+// built again for every page of it that is loaded.
+const timelineTTL = time.Minute * 10
+
+// GetTimeline returns the achievements the user unlocked across every game
+// they have played within [from, to), newest first. A zero from or to leaves
+// that end of the range open.
+//
+// Only unlocks that Steam reports a time for are included.
+func (d *Data) GetTimeline(ctx context.Context, userID string, from, to time.Time) ([]Unlock, error) {
+	key := keyPlayerTimeline.Key(userID)
+	unlocks, err := cache.GetOrLoad(ctx, d.cache, key, timelineTTL, func(ctx context.Context) ([]Unlock, error) {
+		return d.buildTimeline(ctx, userID)
+	}, cache.StaleFor(time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("could not build timeline for %q: %w", userID, err)
+	}
+
+	// Unlocks are sorted newest first, so the range is found by searching
+	start := 0
+	if !to.IsZero() {
+		start = sort.Search(len(unlocks), func(i int) bool { return unlocks[i].UnlockedOn.Before(to) })
+	}
+	end := len(unlocks)
+	if !from.IsZero() {
+		end = sort.Search(len(unlocks), func(i int) bool { return unlocks[i].UnlockedOn.Before(from) })
+	}
+
+	return unlocks[start:max(start, end)], nil
+}
+
+// buildTimeline lists every unlock the user has made, newest first.
+func (d *Data) buildTimeline(ctx context.Context, userID string) ([]Unlock, error) {
+	games, err := d.GetAllAchievements(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []Unlock{}
+	for _, game := range games {
+		for _, achievement := range game.Achievements.Achievements {
+			if !achievement.Achieved || achievement.UnlockedOn == nil {
+				continue
+			}
+
+			ret = append(ret, Unlock{
+				Game:        game.Game,
+				Name:        achievement.Name,
+				Description: achievement.Description,
+				Icon:        achievement.Icon,
+				UnlockedOn:  *achievement.UnlockedOn,
+			})
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].UnlockedOn.Equal(ret[j].UnlockedOn) {
+			return ret[i].UnlockedOn.After(ret[j].UnlockedOn)
+		}
+		if ret[i].Game.DisplayName != ret[j].Game.DisplayName {
+			return ret[i].Game.DisplayName < ret[j].Game.DisplayName
+		}
+		return ret[i].Name < ret[j].Name
+	})
+
+	return ret, nil
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetTimelinePages(t *testing.T) {
+	f := newFakeSteam()
+	now := time.Now().Truncate(time.Second)
+	for i := range 4 {
+		f.users["1"] = append(f.users["1"], fakeGame{
+			ID:           uint64(i + 1),
+			Name:         "Game",
+			Achievements: 4,
+			Unlocked:     2,
+			UnlockedAt:   now.Add(-time.Duration(i+1) * time.Hour),
+			LastPlayed:   now,
+		})
+	}
+	d := newTestData(t, f)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{name: "everything", want: 8},
+		{name: "before", to: now.Add(-2 * time.Hour), want: 4},
+		{name: "after", from: now.Add(-2 * time.Hour), want: 4},
+		{name: "within", from: now.Add(-3 * time.Hour), to: now.Add(-time.Hour), want: 4},
+		{name: "empty", from: now.Add(-time.Hour), to: now.Add(-2 * time.Hour), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.GetTimeline(ctx, "1", tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("got %d unlocks, want %d", len(got), tt.want)
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].UnlockedOn.After(got[i-1].UnlockedOn) {
+					t.Errorf("unlock %d is newer than the one before it", i)
+				}
+			}
+		})
+	}
+
+	// Every page is served from the one cached timeline
+	if cached, err := d.cache.Has(ctx, keyPlayerTimeline.Key("1")); err != nil {
+		t.Fatal(err)
+	} else if !cached {
+		t.Error("got no cached timeline, want it kept for the following pages")
+	}
+}
@@ -0,0 +1,784 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data/cache"
+	"github.com/taiidani/achievements/internal/data/history"
+)
+
+// WebhookFormat is the shape of the payload delivered to a webhook.
+type WebhookFormat string
+
+const (
+	// WebhookJSON delivers the UnlockNotification as-is.
+	WebhookJSON WebhookFormat = "json"
+	// WebhookDiscord delivers a Discord webhook message, with an embed per
+	// achievement.
+	WebhookDiscord WebhookFormat = "discord"
+	// WebhookSlack delivers a Slack incoming webhook message.
+	WebhookSlack WebhookFormat = "slack"
+)
+
+// WebhookFormats lists every supported format.
+var WebhookFormats = []WebhookFormat{WebhookJSON, WebhookDiscord, WebhookSlack}
+
+// Webhook is an endpoint that a user's achievement unlocks are delivered to.
+type Webhook struct {
+	ID     string
+	URL    string
+	Format WebhookFormat
+	// Secret signs every delivery, so that the receiver can verify that it
+	// was sent by this app.
+	Secret    string
+	CreatedAt time.Time
+}
+
+// WebhookDelivery records a single attempt to deliver a notification.
+type WebhookDelivery struct {
+	WebhookID string
+	URL       string
+	Event     string
+	Game      string
+	// Achievements is the number of achievements in the notification.
+	Achievements int
+	Attempt      int
+	// StatusCode is the HTTP status the webhook responded with, or 0 if the
+	// request could not be made.
+	StatusCode  int
+	Error       string `json:",omitempty"`
+	DeliveredAt time.Time
+}
+
+// Succeeded reports whether the webhook accepted the delivery.
+func (d WebhookDelivery) Succeeded() bool {
+	return d.Error == ""
+}
+
+// WebhookEventTest is the event of notifications sent to test a webhook.
+// Every other notification's event is the EventKind it was sent for.
+const WebhookEventTest = "test"
+
+// UnlockNotification is the payload delivered in the json format, describing
+// the achievements a user unlocked in a game or the milestone they reached.
+type UnlockNotification struct {
+	Event      string           `json:"event"`
+	SteamID    string           `json:"steam_id"`
+	User       string           `json:"user"`
+	ProfileURL string           `json:"profile_url"`
+	Game       NotificationGame `json:"game"`
+	// Milestone describes the milestone reached, for milestone events.
+	Milestone    string                    `json:"milestone,omitempty"`
+	Achievements []NotificationAchievement `json:"achievements"`
+}
+
+type NotificationGame struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+}
+
+type NotificationAchievement struct {
+	APIName          string    `json:"api_name"`
+	Name             string    `json:"name"`
+	Description      string    `json:"description"`
+	Icon             string    `json:"icon"`
+	GlobalPercentage float64   `json:"global_percentage"`
+	UnlockedAt       time.Time `json:"unlocked_at"`
+}
+
+// Summary describes the notification in a sentence.
+func (n UnlockNotification) Summary() string {
+	if n.Event == WebhookEventTest {
+		return fmt.Sprintf("Test notification for %s from the Achievement Report", n.User)
+	} else if n.Milestone != "" {
+		return n.User + " " + n.Milestone
+	} else if len(n.Achievements) == 1 {
+		return fmt.Sprintf("%s unlocked %s in %s", n.User, n.Achievements[0].Name, n.Game.Name)
+	}
+	return fmt.Sprintf("%s unlocked %d achievements in %s", n.User, len(n.Achievements), n.Game.Name)
+}
+
+const (
+	// maxWebhooks bounds how many webhooks each user may configure.
+	maxWebhooks = 5
+	// maxWebhookDeliveries is how many deliveries are kept in each user's log.
+	maxWebhookDeliveries = 50
+	// webhookTimeout bounds each delivery request.
+	webhookTimeout = time.Second * 10
+	// maxDiscordEmbeds and maxSlackBlocks are the most embeds and blocks that
+	// Discord and Slack accept in a message.
+	maxDiscordEmbeds = 10
+	maxSlackBlocks   = 50
+)
+
+// webhookClient delivers to user provided URLs, and so must not be used to
+// reach this app's own network. Addresses are checked as they are dialed,
+// after DNS resolution, so that a hostname cannot be rebound to an internal
+// address once it has been checked. Redirects are not followed, as they would
+// be another way around the check.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: webhookDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     time.Minute,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// ErrWebhookInsecure is returned when delivering to a webhook that was added
+// before https was required.
+var ErrWebhookInsecure = errors.New("webhooks must use https")
+
+// ErrWebhookAddress is returned when a webhook resolves to an address that
+// deliveries may not be sent to.
+var ErrWebhookAddress = errors.New("webhooks may not be delivered to loopback, private or link-local addresses")
+
+// webhookDialControl refuses connections to any address that is not publicly
+// routable.
+func webhookDialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !webhookAddressAllowed(ip) {
+		return ErrWebhookAddress
+	}
+	return nil
+}
+
+// webhookDeniedPrefixes are the special purpose ranges that netip does not
+// classify, yet reach hosts that are not on the public internet. Translated
+// IPv6 addresses are denied too, as they may embed any IPv4 address.
+var webhookDeniedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // This network
+	netip.MustParsePrefix("100.64.0.0/10"),  // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // Benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),   // IPv4/IPv6 translation
+	netip.MustParsePrefix("64:ff9b:1::/48"), // Local IPv4/IPv6 translation
+}
+
+// webhookAddressAllowed reports whether deliveries may be sent to the address.
+func webhookAddressAllowed(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, prefix := range webhookDeniedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+
+	return ip.IsValid() &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// ErrTooManyWebhooks is returned when adding a webhook beyond maxWebhooks.
+var ErrTooManyWebhooks = fmt.Errorf("no more than %d webhooks may be configured", maxWebhooks)
+
+// GetWebhooks returns the webhooks configured by the user.
+func (d *Data) GetWebhooks(ctx context.Context, steamID string) ([]Webhook, error) {
+	ret := []Webhook{}
+	err := d.cache.Get(ctx, keyPlayerWebhooks.Key(steamID), &ret)
+	if errors.Is(err, cache.ErrNotFound) {
+		return []Webhook{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get webhooks for %q: %w", steamID, err)
+	}
+	return ret, nil
+}
+
+// AddWebhook configures a new webhook for the user, generating its secret.
+// Only unlocks made after it was added are delivered to it.
+func (d *Data) AddWebhook(ctx context.Context, steamID string, rawURL string, format WebhookFormat) (Webhook, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil || target.Scheme != "https" || target.Hostname() == "" {
+		return Webhook{}, fmt.Errorf("invalid webhook URL %q: %w", rawURL, ErrWebhookInsecure)
+	} else if ip, err := netip.ParseAddr(target.Hostname()); (err == nil && !webhookAddressAllowed(ip)) || strings.EqualFold(target.Hostname(), "localhost") {
+		// Hostnames are checked again on every delivery, once resolved
+		return Webhook{}, ErrWebhookAddress
+	} else if !slices.Contains(WebhookFormats, format) {
+		return Webhook{}, fmt.Errorf("unknown webhook format %q", format)
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return Webhook{}, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return Webhook{}, err
+	}
+	hook := Webhook{
+		ID:        id,
+		URL:       target.String(),
+		Format:    format,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	}
+
+	unlock, err := d.lock(ctx, keyPlayerWebhooks.Key(steamID))
+	if err != nil {
+		return Webhook{}, err
+	}
+	defer unlock()
+
+	hooks, err := d.GetWebhooks(ctx, steamID)
+	if err != nil {
+		return Webhook{}, err
+	} else if len(hooks) >= maxWebhooks {
+		return Webhook{}, ErrTooManyWebhooks
+	}
+
+	return hook, d.setWebhooks(ctx, steamID, append(hooks, hook))
+}
+
+// DeleteWebhook removes one of the user's webhooks.
+func (d *Data) DeleteWebhook(ctx context.Context, steamID string, id string) error {
+	unlock, err := d.lock(ctx, keyPlayerWebhooks.Key(steamID))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hooks, err := d.GetWebhooks(ctx, steamID)
+	if err != nil {
+		return err
+	}
+
+	hooks = slices.DeleteFunc(hooks, func(hook Webhook) bool { return hook.ID == id })
+	return d.setWebhooks(ctx, steamID, hooks)
+}
+
+// RenewWebhooks extends how long the user's webhooks are kept, as they expire
+// along with the user's sessions.
+func (d *Data) RenewWebhooks(ctx context.Context, steamID string) error {
+	unlock, err := d.lock(ctx, keyPlayerWebhooks.Key(steamID))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hooks, err := d.GetWebhooks(ctx, steamID)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+	return d.setWebhooks(ctx, steamID, hooks)
+}
+
+func (d *Data) setWebhooks(ctx context.Context, steamID string, hooks []Webhook) error {
+	key := keyPlayerWebhooks.Key(steamID)
+	if len(hooks) == 0 {
+		return d.cache.Delete(ctx, key)
+	}
+
+	if err := d.cache.Set(ctx, key, hooks, DefaultSessionExpiration); err != nil {
+		return fmt.Errorf("could not save webhooks for %q: %w", steamID, err)
+	}
+	return nil
+}
+
+// WebhookUsers lists the Steam IDs of every user with a webhook configured.
+func (d *Data) WebhookUsers(ctx context.Context) ([]string, error) {
+	keys, err := d.cache.Keys(ctx, keyPlayerWebhooks.Pattern())
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []string{}
+	for _, key := range keys {
+		match, err := keyPlayerWebhooks.Parse(key)
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, match[0])
+	}
+
+	return ret, nil
+}
+
+// GetWebhookDeliveries returns the user's most recent webhook deliveries,
+// newest first.
+func (d *Data) GetWebhookDeliveries(ctx context.Context, steamID string) ([]WebhookDelivery, error) {
+	ret := []WebhookDelivery{}
+	err := d.cache.Get(ctx, keyPlayerWebhookDeliveries.Key(steamID), &ret)
+	if errors.Is(err, cache.ErrNotFound) {
+		return []WebhookDelivery{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not get webhook deliveries for %q: %w", steamID, err)
+	}
+	return ret, nil
+}
+
+func (d *Data) logWebhookDelivery(ctx context.Context, steamID string, delivery WebhookDelivery) {
+	unlock, err := d.lock(ctx, keyPlayerWebhookDeliveries.Key(steamID))
+	if err != nil {
+		slog.Warn("Unable to log webhook delivery", "steam-id", steamID, "error", err)
+		return
+	}
+	defer unlock()
+
+	deliveries, err := d.GetWebhookDeliveries(ctx, steamID)
+	if err != nil {
+		slog.Warn("Unable to log webhook delivery", "steam-id", steamID, "error", err)
+		return
+	}
+
+	deliveries = append([]WebhookDelivery{delivery}, deliveries...)
+	deliveries = deliveries[:min(len(deliveries), maxWebhookDeliveries)]
+	if err := d.cache.Set(ctx, keyPlayerWebhookDeliveries.Key(steamID), deliveries, DefaultSessionExpiration); err != nil {
+		slog.Warn("Unable to log webhook delivery", "steam-id", steamID, "error", err)
+	}
+}
+
+// TestWebhook queues a test notification to one of the user's webhooks.
+func (d *Data) TestWebhook(ctx context.Context, steamID string, id string) (Job, error) {
+	user, err := d.GetUser(ctx, steamID)
+	if err != nil {
+		return Job{}, err
+	}
+
+	notification := UnlockNotification{
+		Event:        WebhookEventTest,
+		SteamID:      steamID,
+		User:         user.Name,
+		ProfileURL:   user.ProfileURL,
+		Achievements: []NotificationAchievement{},
+	}
+	return d.enqueueWebhook(ctx, steamID, id, notification)
+}
+
+// subscribeWebhooks delivers unlocks and milestones to the webhooks of the
+// users they happened to.
+func (d *Data) subscribeWebhooks() {
+	SubscribeTo(d.events, func(ctx context.Context, event AchievementsUnlocked) {
+		d.notifyUnlocks(ctx, event.SteamID, event.GameID, event.Unlocks)
+	})
+	SubscribeTo(d.events, d.notifyMilestone)
+}
+
+// notifyUnlocks delivers newly recorded unlocks to each of the user's
+// webhooks. Unlocks made before a webhook was added are not delivered to it, so
+// that adding one does not replay the user's entire history as it is first
+// recorded. Failures are logged, as notifications are secondary to recording
+// the unlocks themselves.
+func (d *Data) notifyUnlocks(ctx context.Context, steamID string, gameID uint64, events []history.Event) {
+	hooks, err := d.GetWebhooks(ctx, steamID)
+	if err != nil {
+		slog.Warn("Unable to look up webhooks", "steam-id", steamID, "error", err)
+		return
+	} else if len(hooks) == 0 || len(events) == 0 {
+		return
+	}
+
+	notification, err := d.buildUnlockNotification(ctx, steamID, gameID, events)
+	if err != nil {
+		slog.Warn("Unable to build unlock notification", "steam-id", steamID, "game-id", gameID, "error", err)
+		return
+	}
+
+	for _, hook := range hooks {
+		hookNotification := notification
+		hookNotification.Achievements = slices.DeleteFunc(slices.Clone(notification.Achievements), func(achievement NotificationAchievement) bool {
+			return achievement.UnlockedAt.Before(hook.CreatedAt)
+		})
+		if len(hookNotification.Achievements) == 0 {
+			continue
+		}
+
+		if _, err := d.enqueueWebhook(ctx, steamID, hook.ID, hookNotification); err != nil {
+			slog.Warn("Unable to queue webhook delivery", "steam-id", steamID, "webhook-id", hook.ID, "error", err)
+		}
+	}
+}
+
+// notifyMilestone delivers a milestone to each of the user's webhooks.
+func (d *Data) notifyMilestone(ctx context.Context, milestone Milestone) {
+	log := slog.With("steam-id", milestone.User(), "kind", milestone.Kind())
+
+	hooks, err := d.GetWebhooks(ctx, milestone.User())
+	if err != nil {
+		log.Warn("Unable to look up webhooks", "error", err)
+		return
+	} else if len(hooks) == 0 {
+		return
+	}
+
+	summaries, err := d.steam.GetPlayerSummaries(ctx, milestone.User())
+	if err != nil || len(summaries.Response.Players) == 0 {
+		log.Warn("Unable to build milestone notification", "error", err)
+		return
+	}
+	player := summaries.Response.Players[0]
+
+	notification := UnlockNotification{
+		Event:        string(milestone.Kind()),
+		SteamID:      milestone.User(),
+		User:         player.PersonaName,
+		ProfileURL:   player.ProfileURL,
+		Milestone:    milestone.Description(),
+		Achievements: []NotificationAchievement{},
+	}
+	switch event := milestone.(type) {
+	case FirstUnlock:
+		notification.Game = NotificationGame{ID: event.Game.ID, Name: event.Game.DisplayName}
+	case PerfectGame:
+		notification.Game = NotificationGame{ID: event.Game.ID, Name: event.Game.DisplayName}
+	case RarestUnlock:
+		notification.Game = NotificationGame{ID: event.Game.ID, Name: event.Game.DisplayName}
+		achievement := NotificationAchievement{
+			Name:             event.Achievement.Name,
+			Description:      event.Achievement.Description,
+			Icon:             event.Achievement.Icon,
+			GlobalPercentage: event.Achievement.GlobalPercentage,
+		}
+		if event.Achievement.UnlockedOn != nil {
+			achievement.UnlockedAt = *event.Achievement.UnlockedOn
+		}
+		notification.Achievements = append(notification.Achievements, achievement)
+	}
+
+	for _, hook := range hooks {
+		if _, err := d.enqueueWebhook(ctx, milestone.User(), hook.ID, notification); err != nil {
+			log.Warn("Unable to queue webhook delivery", "webhook-id", hook.ID, "error", err)
+		}
+	}
+}
+
+func (d *Data) buildUnlockNotification(ctx context.Context, steamID string, gameID uint64, events []history.Event) (UnlockNotification, error) {
+	summaries, err := d.steam.GetPlayerSummaries(ctx, steamID)
+	if err != nil {
+		return UnlockNotification{}, fmt.Errorf("unable to retrieve user: %w", err)
+	} else if len(summaries.Response.Players) == 0 {
+		return UnlockNotification{}, fmt.Errorf("no user found for ID %q", steamID)
+	}
+	player := summaries.Response.Players[0]
+
+	game, err := d.GetGame(ctx, steamID, gameID)
+	if err != nil {
+		return UnlockNotification{}, err
+	}
+
+	schema, err := d.steam.GetSchemaForGame(ctx, gameID)
+	if err != nil {
+		return UnlockNotification{}, fmt.Errorf("unable to retrieve game schema: %w", err)
+	}
+
+	globalPercentages := map[string]float64{}
+	if globals, err := d.steam.GetGlobalAchievementPercentagesForApp(ctx, gameID); err == nil {
+		for _, global := range globals.AchievementPercentages.Achievements {
+			globalPercentages[global.Name] = global.Percent
+		}
+	}
+
+	ret := UnlockNotification{
+		Event:        string(EventAchievementsUnlocked),
+		SteamID:      steamID,
+		User:         player.PersonaName,
+		ProfileURL:   player.ProfileURL,
+		Game:         NotificationGame{ID: gameID, Name: game.DisplayName},
+		Achievements: []NotificationAchievement{},
+	}
+	for _, event := range events {
+		achievement := NotificationAchievement{
+			APIName:          event.APIName,
+			Name:             event.APIName,
+			GlobalPercentage: globalPercentages[event.APIName],
+			UnlockedAt:       event.UnlockedAt,
+		}
+		for _, schemaAchievement := range schema.Game.AvailableGameStats.Achievements {
+			if schemaAchievement.Name == event.APIName {
+				achievement.Name = schemaAchievement.DisplayName
+				achievement.Description = schemaAchievement.Description
+				achievement.Icon = schemaAchievement.Icon
+				break
+			}
+		}
+		ret.Achievements = append(ret.Achievements, achievement)
+	}
+
+	slices.SortFunc(ret.Achievements, func(a, b NotificationAchievement) int {
+		return a.UnlockedAt.Compare(b.UnlockedAt)
+	})
+	return ret, nil
+}
+
+func (d *Data) enqueueWebhook(ctx context.Context, steamID string, id string, notification UnlockNotification) (Job, error) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return Job{}, err
+	}
+
+	return d.EnqueueJob(ctx, JobDeliverWebhook, map[string]string{
+		"steam-id":     steamID,
+		"webhook-id":   id,
+		"notification": string(body),
+	})
+}
+
+// deliverWebhook sends a queued notification, logging the attempt. Errors are
+// returned so that the job is retried.
+func (d *Data) deliverWebhook(ctx context.Context, job Job) error {
+	steamID := job.Args["steam-id"]
+	log := slog.With("steam-id", steamID, "webhook-id", job.Args["webhook-id"])
+
+	notification := UnlockNotification{}
+	if err := json.Unmarshal([]byte(job.Args["notification"]), &notification); err != nil {
+		return fmt.Errorf("could not decode notification: %w", err)
+	}
+
+	hooks, err := d.GetWebhooks(ctx, steamID)
+	if err != nil {
+		return err
+	}
+	ix := slices.IndexFunc(hooks, func(hook Webhook) bool { return hook.ID == job.Args["webhook-id"] })
+	if ix < 0 {
+		log.Info("Skipping delivery to deleted webhook")
+		return nil
+	}
+	hook := hooks[ix]
+
+	delivery := WebhookDelivery{
+		WebhookID:    hook.ID,
+		URL:          hook.URL,
+		Event:        notification.Event,
+		Game:         notification.Game.Name,
+		Achievements: len(notification.Achievements),
+		Attempt:      job.Attempts,
+		DeliveredAt:  time.Now(),
+	}
+	delivery.StatusCode, err = postWebhook(ctx, hook, notification)
+	if err != nil {
+		delivery.Error = webhookDeliveryError(delivery.StatusCode, err)
+	}
+	d.logWebhookDelivery(ctx, steamID, delivery)
+
+	if err != nil {
+		log.Warn("Unable to deliver webhook", "event", notification.Event, "status", delivery.StatusCode, "error", err)
+		return fmt.Errorf("could not deliver to webhook %q: %w", hook.ID, err)
+	}
+	log.Info("Delivered webhook", "event", notification.Event, "status", delivery.StatusCode)
+	return nil
+}
+
+// postWebhook sends the notification to the webhook in its format, returning
+// the status code it responded with.
+//
+// Every request is signed with the webhook's secret. The
+// X-Achievements-Signature header holds "sha256=" followed by the hex encoded
+// HMAC-SHA256 of the X-Achievements-Timestamp header, a ".", and the body.
+func postWebhook(ctx context.Context, hook Webhook, notification UnlockNotification) (int, error) {
+	body, err := webhookPayload(hook.Format, notification)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("could not format request: %w", err)
+	}
+	if req.URL.Scheme != "https" {
+		return 0, ErrWebhookInsecure
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "achievements-webhook")
+	req.Header.Set("X-Achievements-Event", notification.Event)
+	req.Header.Set("X-Achievements-Timestamp", timestamp)
+	req.Header.Set("X-Achievements-Signature", "sha256="+signWebhook(hook.Secret, timestamp, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookDeliveryError describes a failed delivery to the user that owns the
+// webhook. Connection errors are not described in detail, so that the log
+// cannot be used to probe the network the app runs in.
+func webhookDeliveryError(statusCode int, err error) string {
+	var netErr net.Error
+	switch {
+	case statusCode != 0:
+		return fmt.Sprintf("unexpected response: %d %s", statusCode, http.StatusText(statusCode))
+	case errors.Is(err, ErrWebhookAddress):
+		return ErrWebhookAddress.Error()
+	case errors.Is(err, ErrWebhookInsecure):
+		return ErrWebhookInsecure.Error()
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()):
+		return "timed out"
+	default:
+		return "could not connect to the webhook"
+	}
+}
+
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookPayload renders the notification in the given format.
+func webhookPayload(format WebhookFormat, notification UnlockNotification) ([]byte, error) {
+	switch format {
+	case WebhookJSON:
+		return json.Marshal(notification)
+	case WebhookDiscord:
+		return json.Marshal(discordPayload(notification))
+	case WebhookSlack:
+		return json.Marshal(slackPayload(notification))
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+}
+
+type discordMessage struct {
+	Content string         `json:"content"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	Timestamp   string       `json:"timestamp,omitempty"`
+	Thumbnail   *discordURL  `json:"thumbnail,omitempty"`
+	Footer      *discordText `json:"footer,omitempty"`
+}
+
+type discordURL struct {
+	URL string `json:"url"`
+}
+
+type discordText struct {
+	Text string `json:"text"`
+}
+
+func discordPayload(notification UnlockNotification) discordMessage {
+	ret := discordMessage{Content: notification.Summary()}
+	for _, achievement := range notification.Achievements {
+		if len(ret.Embeds) == maxDiscordEmbeds {
+			break
+		}
+
+		embed := discordEmbed{
+			Title:       achievement.Name,
+			Description: achievement.Description,
+			Timestamp:   achievement.UnlockedAt.Format(time.RFC3339),
+		}
+		if achievement.Icon != "" {
+			embed.Thumbnail = &discordURL{URL: achievement.Icon}
+		}
+		if achievement.GlobalPercentage > 0 {
+			embed.Footer = &discordText{Text: fmt.Sprintf("Unlocked by %.1f%% of players", achievement.GlobalPercentage)}
+		}
+		ret.Embeds = append(ret.Embeds, embed)
+	}
+	return ret
+}
+
+type slackMessage struct {
+	Text   string       `json:"text"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type      string      `json:"type"`
+	Text      *slackText  `json:"text,omitempty"`
+	Accessory *slackImage `json:"accessory,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackImage struct {
+	Type     string `json:"type"`
+	ImageURL string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+func slackPayload(notification UnlockNotification) slackMessage {
+	ret := slackMessage{
+		Text:   notification.Summary(),
+		Blocks: []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: slackEscape(notification.Summary())}}},
+	}
+
+	for _, achievement := range notification.Achievements {
+		if len(ret.Blocks) == maxSlackBlocks {
+			break
+		}
+
+		text := "*" + slackEscape(achievement.Name) + "*"
+		if achievement.Description != "" {
+			text += "\n" + slackEscape(achievement.Description)
+		}
+		if achievement.GlobalPercentage > 0 {
+			text += fmt.Sprintf("\n_Unlocked by %.1f%% of players_", achievement.GlobalPercentage)
+		}
+
+		block := slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}}
+		if achievement.Icon != "" {
+			block.Accessory = &slackImage{Type: "image", ImageURL: achievement.Icon, AltText: achievement.Name}
+		}
+		ret.Blocks = append(ret.Blocks, block)
+	}
+	return ret
+}
+
+// slackEscape escapes the characters that Slack treats as control sequences.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+func randomHex(n int) (string, error) {
+	ret := make([]byte, n)
+	if _, err := rand.Read(ret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(ret), nil
+}
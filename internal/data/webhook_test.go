@@ -0,0 +1,65 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestWebhookAddressAllowed(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.215.14":         true,
+		"2606:2800:21f:cb07::1": true,
+		"127.0.0.1":             false,
+		"::1":                   false,
+		"10.0.0.1":              false,
+		"172.16.0.1":            false,
+		"192.168.1.1":           false,
+		"fd00::1":               false,
+		"169.254.169.254":       false,
+		"fe80::1":               false,
+		"224.0.0.1":             false,
+		"0.0.0.0":               false,
+		"::":                    false,
+		"::ffff:127.0.0.1":      false,
+		"0.1.2.3":               false,
+		"100.64.0.1":            false,
+		"100.127.255.254":       false,
+		"100.128.0.1":           true,
+		"192.0.0.8":             false,
+		"198.18.0.1":            false,
+		"198.19.255.254":        false,
+		"198.20.0.1":            true,
+		"64:ff9b::a9fe:a9fe":    false,
+		"64:ff9b:1::7f00:1":     false,
+		"::ffff:100.64.0.1":     false,
+	}
+
+	for addr, want := range tests {
+		if got := webhookAddressAllowed(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("webhookAddressAllowed(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestAddWebhookAddress(t *testing.T) {
+	d := newTestData(t, newFakeSteam())
+	ctx := context.Background()
+
+	tests := map[string]error{
+		"https://example.com/hook":     nil,
+		"http://example.com/hook":      ErrWebhookInsecure,
+		"https://localhost/hook":       ErrWebhookAddress,
+		"https://127.0.0.1/hook":       ErrWebhookAddress,
+		"https://100.64.0.1/hook":      ErrWebhookAddress,
+		"https://[64:ff9b::7f00:1]/hk": ErrWebhookAddress,
+	}
+
+	for rawURL, want := range tests {
+		_, err := d.AddWebhook(ctx, "1", rawURL, WebhookFormats[0])
+		if !errors.Is(err, want) {
+			t.Errorf("AddWebhook(%q) = %v, want %v", rawURL, err, want)
+		}
+	}
+}
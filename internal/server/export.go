@@ -0,0 +1,55 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/taiidani/achievements/internal/data"
+)
+
+func (s *Server) exportHandler(resp http.ResponseWriter, req *http.Request) {
+	steamID := req.PathValue("steamid")
+	if len(steamID) == 0 {
+		errorResponse(resp, http.StatusBadRequest, fmt.Errorf("user ID is required"))
+		return
+	}
+
+	query := req.URL.Query()
+	if !query.Has("format") {
+		query.Set("format", string(data.ExportCSV))
+	}
+	if !query.Has("scope") {
+		query.Set("scope", "all")
+	}
+
+	format, err := data.ParseExportFormat(query.Get("format"))
+	if err != nil {
+		errorResponse(resp, http.StatusBadRequest, err)
+		return
+	}
+
+	scope, err := data.ParseExportScope(query.Get("scope"))
+	if err != nil {
+		errorResponse(resp, http.StatusBadRequest, err)
+		return
+	}
+
+	export, err := s.backend.NewExport(req.Context(), steamID, scope)
+	if err != nil {
+		errorResponse(resp, http.StatusNotFound, err)
+		return
+	}
+
+	filename := "achievements-" + steamID
+	if scope.GameID != 0 {
+		filename += fmt.Sprintf("-%d", scope.GameID)
+	}
+	resp.Header().Set("Content-Type", format.ContentType())
+	resp.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, filename, format))
+
+	// The response has already begun, so errors can only be logged
+	if err := export.Write(req.Context(), resp, format); err != nil {
+		slog.Error("Export failed part way through", "steam-id", steamID, "error", err)
+	}
+}
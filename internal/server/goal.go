@@ -0,0 +1,208 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data"
+)
+
+type goalsBag struct {
+	baseBag
+	SteamID string
+	User    data.User
+	Goals   []data.GoalProgress
+	// Games are offered for goals to complete a single game, by name.
+	Games     []data.Game
+	HasPinned bool
+	// Error reports why the submitted change could not be made.
+	Error error
+}
+
+func (s *Server) goalsHandler(resp http.ResponseWriter, req *http.Request) {
+	bag := goalsBag{baseBag: s.newBag(req, "goals")}
+
+	bag.SteamID = req.PathValue("steamid")
+	if len(bag.SteamID) == 0 {
+		errorResponse(resp, http.StatusBadRequest, fmt.Errorf("user ID is required"))
+		return
+	}
+
+	// Goals are private to the user that set them
+	if bag.Session == nil || bag.Session.SteamID != bag.SteamID {
+		errorResponse(resp, http.StatusForbidden, fmt.Errorf("only the logged in user may manage their goals"))
+		return
+	}
+	bag.User = *bag.SessionUser
+	bag.HasPinned = len(bag.Session.Pinned) > 0
+
+	code := http.StatusOK
+	if req.Method == http.MethodPost {
+		var err error
+		switch req.FormValue("action") {
+		case "add":
+			var goal data.Goal
+			goal, err = parseGoalForm(req, bag.Session.Pinned)
+			if err == nil {
+				_, err = s.backend.AddGoal(req.Context(), bag.SteamID, goal)
+			}
+		case "delete":
+			err = s.backend.DeleteGoal(req.Context(), bag.SteamID, req.FormValue("id"))
+		default:
+			err = fmt.Errorf("unknown action %q", req.FormValue("action"))
+		}
+
+		if err == nil {
+			http.Redirect(resp, req, req.URL.Path, http.StatusSeeOther)
+			return
+		}
+		bag.Error = err
+		code = http.StatusBadRequest
+	}
+
+	var err error
+	bag.Goals, err = s.backend.GetUserGoalProgress(req.Context(), bag.SteamID)
+	if err != nil {
+		errorResponse(resp, http.StatusInternalServerError, err)
+		return
+	}
+
+	bag.Games, err = s.backend.GetGames(req.Context(), bag.SteamID)
+	if err != nil {
+		errorResponse(resp, http.StatusInternalServerError, err)
+		return
+	}
+	slices.SortFunc(bag.Games, func(a, b data.Game) int {
+		return strings.Compare(strings.ToLower(a.DisplayName), strings.ToLower(b.DisplayName))
+	})
+
+	renderHtml(resp, code, "goals.gohtml", bag)
+}
+
+// parseGoalForm reads a goal from a submitted form. Goals to complete the
+// pinned games are set for the given pins, as they were when the goal was set.
+func parseGoalForm(req *http.Request, pinned []uint64) (data.Goal, error) {
+	goal := data.Goal{Title: req.FormValue("title")}
+
+	switch req.FormValue("kind") {
+	case "complete-game":
+		gameID, err := strconv.ParseUint(req.FormValue("game"), 10, 64)
+		if err != nil || gameID == 0 {
+			return data.Goal{}, fmt.Errorf("choose a game to complete")
+		}
+		goal.Kind = data.GoalCompleteGames
+		goal.GameIDs = []uint64{gameID}
+	case "complete-pinned":
+		if len(pinned) == 0 {
+			return data.Goal{}, fmt.Errorf("pin the games to complete first")
+		}
+		goal.Kind = data.GoalCompleteGames
+		goal.GameIDs = slices.Clone(pinned)
+	case "unlock-count":
+		target, err := strconv.Atoi(req.FormValue("target"))
+		if err != nil {
+			return data.Goal{}, fmt.Errorf("enter the number of achievements to unlock")
+		}
+		goal.Kind = data.GoalUnlockCount
+		goal.Target = target
+	default:
+		return data.Goal{}, fmt.Errorf("unknown goal kind %q", req.FormValue("kind"))
+	}
+
+	var err error
+	if goal.Start, err = parseGoalDate(req.FormValue("start")); err != nil {
+		return data.Goal{}, fmt.Errorf("invalid start date: %w", err)
+	}
+
+	// Goals are due by the end of their deadline's day
+	if goal.Deadline, err = parseGoalDate(req.FormValue("deadline")); err != nil {
+		return data.Goal{}, fmt.Errorf("invalid deadline: %w", err)
+	} else if !goal.Deadline.IsZero() {
+		goal.Deadline = goal.Deadline.AddDate(0, 0, 1).Add(-time.Second)
+	}
+
+	return goal, nil
+}
+
+// parseGoalDate parses a date input, which may be left empty.
+func parseGoalDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(time.DateOnly, value, time.Local)
+}
+
+type hxGoalsBag struct {
+	baseBag
+	SteamID string
+	Goals   []data.GoalProgress
+	// More counts the goals left out of the widget.
+	More int
+}
+
+// hxGoalsLimit is the most goals shown in the home page widget.
+const hxGoalsLimit = 5
+
+func (s *Server) hxUserGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	bag := hxGoalsBag{baseBag: s.newBag(r, "")}
+
+	bag.SteamID = r.PathValue("steamid")
+	if len(bag.SteamID) == 0 {
+		errorResponse(w, http.StatusBadRequest, fmt.Errorf("user ID is required"))
+		return
+	}
+
+	if bag.Session == nil || bag.Session.SteamID != bag.SteamID {
+		errorResponse(w, http.StatusForbidden, fmt.Errorf("only the logged in user may view their goals"))
+		return
+	}
+
+	goals, err := s.backend.GetUserGoalProgress(r.Context(), bag.SteamID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, err)
+		return
+	}
+
+	// Goals that were met or missed are only listed on the goals page
+	goals = slices.DeleteFunc(goals, func(goal data.GoalProgress) bool {
+		return goal.Done() || goal.Status == data.GoalMissed
+	})
+	bag.Goals = goals[:min(len(goals), hxGoalsLimit)]
+	bag.More = len(goals) - len(bag.Goals)
+
+	renderHtml(w, http.StatusOK, "hx-goals.gohtml", bag)
+}
+
+type hxGroupGoalsBag struct {
+	baseBag
+	Group data.Group
+	Goals []data.GroupGoalProgress
+}
+
+func (s *Server) hxGroupGoalsHandler(w http.ResponseWriter, r *http.Request) {
+	bag := hxGroupGoalsBag{baseBag: s.newBag(r, "")}
+
+	var err error
+	bag.Group, err = s.backend.GetGroup(r.Context(), r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, groupErrorCode(err), err)
+		return
+	}
+
+	if bag.Session == nil || !bag.Group.IsMember(bag.Session.SteamID) {
+		errorResponse(w, http.StatusForbidden, fmt.Errorf("only members may view this group"))
+		return
+	}
+
+	bag.Goals, err = s.backend.GetGroupGoalProgress(r.Context(), bag.Group)
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	renderHtml(w, http.StatusOK, "hx-group-goals.gohtml", bag)
+}
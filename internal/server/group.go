@@ -0,0 +1,201 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/taiidani/achievements/internal/data"
+)
+
+type groupsBag struct {
+	baseBag
+	Groups []data.Group
+	// Error reports why the group could not be created.
+	Error error
+}
+
+func (s *Server) groupsHandler(resp http.ResponseWriter, req *http.Request) {
+	bag := groupsBag{baseBag: s.newBag(req, "groups")}
+
+	if bag.Session == nil {
+		http.Redirect(resp, req, "/user/login", http.StatusTemporaryRedirect)
+		return
+	}
+
+	code := http.StatusOK
+	if req.Method == http.MethodPost {
+		group, err := s.backend.CreateGroup(req.Context(), bag.Session.SteamID, req.FormValue("name"))
+		if err == nil {
+			http.Redirect(resp, req, "/group/"+group.ID, http.StatusSeeOther)
+			return
+		}
+		bag.Error = err
+		code = http.StatusBadRequest
+	}
+
+	var err error
+	bag.Groups, err = s.backend.GetUserGroups(req.Context(), bag.Session.SteamID)
+	if err != nil {
+		errorResponse(resp, http.StatusInternalServerError, err)
+		return
+	}
+
+	renderHtml(resp, code, "groups.gohtml", bag)
+}
+
+type groupBag struct {
+	baseBag
+	Group       data.Group
+	Leaderboard data.Leaderboard
+	Sorts       []data.LeaderboardSort
+	// InviteURL is shown to the group's owner, for members to join through.
+	InviteURL string
+	// Token is set when a user that is not a member follows an invite link.
+	Token string
+	// Error reports why the submitted change could not be made.
+	Error error
+}
+
+func (s *Server) groupHandler(resp http.ResponseWriter, req *http.Request) {
+	bag := groupBag{baseBag: s.newBag(req, "groups"), Sorts: data.LeaderboardSorts}
+
+	group, err := s.backend.GetGroup(req.Context(), req.PathValue("id"))
+	if err != nil {
+		errorResponse(resp, groupErrorCode(err), err)
+		return
+	}
+	bag.Group = group
+
+	if bag.Session == nil {
+		http.Redirect(resp, req, "/user/login", http.StatusTemporaryRedirect)
+		return
+	}
+	steamID := bag.Session.SteamID
+
+	code := http.StatusOK
+	if req.Method == http.MethodPost {
+		err := s.changeGroup(req, bag.Group, steamID)
+		if err == nil {
+			// Leaving or deleting the group revokes access to its page
+			target := req.URL.Path
+			if action := req.FormValue("action"); action == "delete" || action == "leave" {
+				target = "/groups"
+			}
+			http.Redirect(resp, req, target, http.StatusSeeOther)
+			return
+		}
+		bag.Error = err
+		code = groupErrorCode(err)
+	}
+
+	// Users outside of the group may only see the invitation to join it
+	if !bag.Group.IsMember(steamID) {
+		bag.Token = req.URL.Query().Get("token")
+		if bag.Token != bag.Group.JoinToken {
+			errorResponse(resp, http.StatusForbidden, fmt.Errorf("only members may view this group"))
+			return
+		}
+
+		renderHtml(resp, code, "group-join.gohtml", bag)
+		return
+	}
+
+	if bag.Group.IsOwner(steamID) {
+		bag.InviteURL = s.publicURL + "/group/" + bag.Group.ID + "?" + url.Values{"token": {bag.Group.JoinToken}}.Encode()
+	}
+
+	bag.Leaderboard, err = s.backend.GetLeaderboard(req.Context(), bag.Group, data.LeaderboardSort(req.URL.Query().Get("sort")))
+	if err != nil {
+		errorResponse(resp, http.StatusInternalServerError, err)
+		return
+	}
+
+	renderHtml(resp, code, "group.gohtml", bag)
+}
+
+// changeGroup applies the change to the group submitted by the user.
+func (s *Server) changeGroup(req *http.Request, group data.Group, steamID string) error {
+	ctx := req.Context()
+
+	var err error
+	switch req.FormValue("action") {
+	case "join":
+		_, err = s.backend.JoinGroup(ctx, group.ID, req.FormValue("token"), steamID)
+	case "add":
+		var member data.User
+		member, err = s.resolveUser(ctx, req.FormValue("member"))
+		if err == nil {
+			_, err = s.backend.AddGroupMember(ctx, group.ID, steamID, member.SteamID)
+		}
+	case "remove":
+		_, err = s.backend.RemoveGroupMember(ctx, group.ID, steamID, req.FormValue("member"))
+	case "leave":
+		_, err = s.backend.RemoveGroupMember(ctx, group.ID, steamID, steamID)
+	case "reset-invite":
+		_, err = s.backend.ResetGroupInvite(ctx, group.ID, steamID)
+	case "delete":
+		err = s.backend.DeleteGroup(ctx, group.ID, steamID)
+	case "add-goal":
+		var goal data.Goal
+		goal, err = parseGoalForm(req, nil)
+		if err == nil {
+			_, err = s.backend.AddGroupGoal(ctx, group.ID, steamID, goal)
+		}
+	case "delete-goal":
+		_, err = s.backend.DeleteGroupGoal(ctx, group.ID, steamID, req.FormValue("goal"))
+	default:
+		err = fmt.Errorf("unknown action %q", req.FormValue("action"))
+	}
+
+	return err
+}
+
+type groupGameBag struct {
+	baseBag
+	Group       data.Group
+	Leaderboard data.GameLeaderboard
+}
+
+func (s *Server) groupGameHandler(resp http.ResponseWriter, req *http.Request) {
+	bag := groupGameBag{baseBag: s.newBag(req, "groups")}
+
+	gameID, err := strconv.ParseUint(req.PathValue("gameid"), 10, 64)
+	if err != nil {
+		errorResponse(resp, http.StatusBadRequest, fmt.Errorf("invalid game ID: %w", err))
+		return
+	}
+
+	bag.Group, err = s.backend.GetGroup(req.Context(), req.PathValue("id"))
+	if err != nil {
+		errorResponse(resp, groupErrorCode(err), err)
+		return
+	}
+
+	if bag.Session == nil || !bag.Group.IsMember(bag.Session.SteamID) {
+		errorResponse(resp, http.StatusForbidden, fmt.Errorf("only members may view this group"))
+		return
+	}
+
+	bag.Leaderboard, err = s.backend.GetGameLeaderboard(req.Context(), bag.Group, gameID)
+	if err != nil {
+		errorResponse(resp, http.StatusInternalServerError, err)
+		return
+	}
+
+	renderHtml(resp, http.StatusOK, "group-game.gohtml", bag)
+}
+
+// groupErrorCode is the status code to respond to a group error with.
+func groupErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrGroupNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrNotGroupOwner), errors.Is(err, data.ErrInvalidJoinToken):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
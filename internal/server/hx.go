@@ -11,8 +11,8 @@ import (
 
 type hxGameRowBag struct {
 	baseBag
-	GameID       uint64
-	Achievements data.Achievements
+	GameID   uint64
+	Progress data.GameProgress
 }
 
 func (s *Server) hxGameRowHandler(w http.ResponseWriter, r *http.Request) {
@@ -36,7 +36,7 @@ func (s *Server) hxGameRowHandler(w http.ResponseWriter, r *http.Request) {
 		errorResponse(w, http.StatusNotFound, err)
 		return
 	}
-	bag.Achievements = achievements
+	bag.Progress = achievements.Progress()
 
 	renderHtml(w, http.StatusOK, "hx-achievement-progress.gohtml", bag)
 }
@@ -86,3 +86,78 @@ func (s *Server) hxGamePinHandler(w http.ResponseWriter, r *http.Request) {
 
 	renderHtml(w, http.StatusOK, "games-pinned.gohtml", bag)
 }
+
+type hxUserScoreBag struct {
+	baseBag
+	SteamID string
+	Points  int
+}
+
+func (s *Server) hxUserScoreHandler(w http.ResponseWriter, r *http.Request) {
+	bag := hxUserScoreBag{baseBag: s.newBag(r, "")}
+
+	bag.SteamID = r.PathValue("steamid")
+	if len(bag.SteamID) == 0 {
+		errorResponse(w, http.StatusBadRequest, fmt.Errorf("user ID is required"))
+		return
+	}
+
+	// The summary is enough to total the score, without computing every
+	// other statistic
+	summary, err := s.backend.GetCachedSummary(r.Context(), bag.SteamID)
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, err)
+		return
+	}
+	bag.Points = summary.Points()
+
+	renderHtml(w, http.StatusOK, "hx-user-score.gohtml", bag)
+}
+
+type hxJobBag struct {
+	baseBag
+	Job data.Job
+}
+
+func (s *Server) hxUserRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	bag := hxJobBag{baseBag: s.newBag(r, "")}
+
+	if r.Method != http.MethodPost {
+		errorResponse(w, http.StatusMethodNotAllowed, fmt.Errorf("refreshes must be requested with POST"))
+		return
+	}
+
+	steamID := r.PathValue("steamid")
+	if len(steamID) == 0 {
+		errorResponse(w, http.StatusBadRequest, fmt.Errorf("user ID is required"))
+		return
+	}
+
+	// Refreshing is costly, so users may only refresh their own data
+	if bag.Session == nil || bag.Session.SteamID != steamID {
+		errorResponse(w, http.StatusForbidden, fmt.Errorf("only the logged in user may refresh their data"))
+		return
+	}
+
+	var err error
+	bag.Job, err = s.backend.EnqueueJob(r.Context(), data.JobRefreshSummary, map[string]string{"steam-id": steamID})
+	if err != nil {
+		errorResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	renderHtml(w, http.StatusAccepted, "hx-job.gohtml", bag)
+}
+
+func (s *Server) hxJobHandler(w http.ResponseWriter, r *http.Request) {
+	bag := hxJobBag{baseBag: s.newBag(r, "")}
+
+	var err error
+	bag.Job, err = s.backend.GetJob(r.Context(), r.PathValue("id"))
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, err)
+		return
+	}
+
+	renderHtml(w, http.StatusOK, "hx-job.gohtml", bag)
+}
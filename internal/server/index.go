@@ -3,9 +3,14 @@ package server
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
 	"slices"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/taiidani/achievements/internal/data"
 )
@@ -15,12 +20,33 @@ type indexBag struct {
 	SteamID   string
 	User      data.User
 	HasPinned bool
-	Games     []indexBagGame
+	// Games lists every game, for the pinned section. Filtered holds the games
+	// matching the Query, in its order.
+	Games    []indexBagGame
+	Filtered []indexBagGame
+	Query    gamesQuery
+}
+
+// Incomplete reports whether the filtered games include any whose progress is
+// still loading, which the query may not have been applied to correctly.
+func (b indexBag) Incomplete() bool {
+	if !b.Query.filtersCompletion() && !b.Query.sortsByProgress() {
+		return false
+	}
+	return slices.ContainsFunc(b.Filtered, func(game indexBagGame) bool { return game.Progress == nil })
 }
 
 type indexBagGame struct {
 	data.Game
 	Pinned bool
+	// Progress is nil when the game's achievements could not be summarized.
+	Progress *data.GameProgress
+}
+
+// HasAchievements reports whether the game has achievements, assuming that it
+// does when that is unknown.
+func (g indexBagGame) HasAchievements() bool {
+	return g.Progress == nil || g.Progress.Total > 0
 }
 
 func (s *Server) indexHandler(resp http.ResponseWriter, req *http.Request) {
@@ -42,6 +68,9 @@ func (s *Server) indexHandler(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	bag.Query = parseGamesQuery(req.URL.Query())
+	bag.Filtered = filterGamesList(bag.Games, bag.Query)
+
 	template := "games.gohtml"
 	renderHtml(resp, http.StatusOK, template, bag)
 }
@@ -70,10 +99,16 @@ func (s *Server) gamesHandler(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	bag.Query = parseGamesQuery(req.URL.Query())
+	bag.Filtered = filterGamesList(bag.Games, bag.Query)
+
 	template := "games.gohtml"
 	renderHtml(resp, http.StatusOK, template, bag)
 }
 
+// loadGamesList returns every game the user has played alongside their
+// progress in it, sorted by name. Progress comes from the cached summary and
+// is left nil for games it does not have yet, for their rows to load it.
 func (s *Server) loadGamesList(ctx context.Context, steamID string, bag baseBag) ([]indexBagGame, bool, error) {
 	ret := []indexBagGame{}
 	retPinned := false
@@ -83,18 +118,21 @@ func (s *Server) loadGamesList(ctx context.Context, steamID string, bag baseBag)
 		return ret, false, err
 	}
 
-	for _, game := range games {
-		if ok, err := s.backend.HasAchievements(ctx, game.ID); err != nil {
-			return ret, false, fmt.Errorf("could not retrieve achievements: %w", err)
-		} else if !ok {
-			continue
-		}
+	summary, err := s.backend.GetCachedSummary(ctx, steamID)
+	if err != nil {
+		return ret, false, fmt.Errorf("could not retrieve achievements: %w", err)
+	}
 
+	for _, game := range games {
 		bagGame := indexBagGame{
 			Game:   game,
 			Pinned: bag.Session != nil && slices.Contains(bag.Session.Pinned, game.ID),
 		}
 
+		if progress, ok := summary.Games[game.ID]; ok {
+			bagGame.Progress = &progress
+		}
+
 		if bagGame.Pinned {
 			retPinned = true
 		}
@@ -108,3 +146,140 @@ func (s *Server) loadGamesList(ctx context.Context, steamID string, bag baseBag)
 
 	return ret, retPinned, nil
 }
+
+// gamesQuery filters and sorts the games list, as given in the URL.
+type gamesQuery struct {
+	// Sort is one of "name", "completion", "playtime", "last-played" or
+	// "remaining".
+	Sort   string
+	Search string
+	// MinCompletion and MaxCompletion bound the percentage of achievements
+	// unlocked.
+	MinCompletion int
+	MaxCompletion int
+	// PlayedWithin is the number of months a game must have been played
+	// within, or 0 for any time.
+	PlayedWithin int
+	Perfect      bool
+	NeverStarted bool
+	// All includes games without achievements.
+	All bool
+}
+
+func parseGamesQuery(values url.Values) gamesQuery {
+	ret := gamesQuery{
+		Sort:          values.Get("sort"),
+		Search:        strings.TrimSpace(values.Get("q")),
+		MaxCompletion: 100,
+		Perfect:       values.Get("perfect") == "true",
+		NeverStarted:  values.Get("never-started") == "true",
+		All:           values.Get("all") == "true",
+	}
+
+	switch ret.Sort {
+	case "completion", "playtime", "last-played", "remaining":
+	default:
+		ret.Sort = "name"
+	}
+
+	if pct, err := strconv.Atoi(values.Get("min")); err == nil {
+		ret.MinCompletion = min(max(pct, 0), 100)
+	}
+	if pct, err := strconv.Atoi(values.Get("max")); err == nil {
+		ret.MaxCompletion = min(max(pct, 0), 100)
+	}
+	if months, err := strconv.Atoi(values.Get("played-within")); err == nil && months > 0 {
+		ret.PlayedWithin = months
+	}
+
+	return ret
+}
+
+// filtersCompletion reports whether the query filters on the achievements
+// unlocked in each game.
+func (q gamesQuery) filtersCompletion() bool {
+	return q.MinCompletion > 0 || q.MaxCompletion < 100 || q.Perfect || q.NeverStarted
+}
+
+// sortsByProgress reports whether the query sorts games by the achievements
+// unlocked in each.
+func (q gamesQuery) sortsByProgress() bool {
+	return q.Sort == "completion" || q.Sort == "remaining"
+}
+
+// matchesCompletion reports whether a game's progress passes the completion
+// filters. Games whose progress is still loading pass, to be listed as pending
+// rather than silently dropped. Games without achievements have no
+// completion, so only pass when there are no completion filters.
+func (q gamesQuery) matchesCompletion(p *data.GameProgress) bool {
+	if p == nil {
+		return true
+	} else if p.Total == 0 {
+		return !q.filtersCompletion()
+	}
+
+	switch {
+	case p.Percentage < q.MinCompletion || p.Percentage > q.MaxCompletion:
+		return false
+	case q.Perfect && p.Unlocked < p.Total:
+		return false
+	case q.NeverStarted && p.Unlocked > 0:
+		return false
+	}
+	return true
+}
+
+// filterGamesList applies the query to the games list.
+func filterGamesList(games []indexBagGame, q gamesQuery) []indexBagGame {
+	search := strings.ToLower(q.Search)
+	ret := []indexBagGame{}
+	for _, game := range games {
+		if !game.HasAchievements() && !q.All {
+			continue
+		} else if search != "" && !strings.Contains(strings.ToLower(game.DisplayName), search) {
+			continue
+		} else if q.PlayedWithin > 0 && (game.LastPlayed.IsZero() || game.LastPlayed.Before(time.Now().AddDate(0, -q.PlayedWithin, 0))) {
+			continue
+		} else if !q.matchesCompletion(game.Progress) {
+			continue
+		}
+
+		ret = append(ret, game)
+	}
+
+	// Games are already sorted by name, which breaks any ties
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		switch q.Sort {
+		case "completion":
+			return completion(a) > completion(b)
+		case "playtime":
+			return a.PlaytimeForever > b.PlaytimeForever
+		case "last-played":
+			return a.LastPlayed.After(b.LastPlayed)
+		case "remaining":
+			return remaining(a) < remaining(b)
+		}
+		return false
+	})
+
+	return ret
+}
+
+// completion is the percentage of a game's achievements unlocked, or -1 for
+// games without achievements or still loading so that they sort last.
+func completion(game indexBagGame) int {
+	if game.Progress == nil || game.Progress.Total == 0 {
+		return -1
+	}
+	return game.Progress.Percentage
+}
+
+// remaining is the number of a game's achievements still locked. Games without
+// any left to unlock, or still loading, sort last.
+func remaining(game indexBagGame) int {
+	if game.Progress == nil || game.Progress.Unlocked == game.Progress.Total {
+		return math.MaxInt
+	}
+	return game.Progress.Total - game.Progress.Unlocked
+}
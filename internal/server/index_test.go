@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data"
+)
+
+func TestFilterGamesListPending(t *testing.T) {
+	games := []indexBagGame{
+		{Game: data.Game{ID: 1, DisplayName: "Loading"}},
+		{Game: data.Game{ID: 2, DisplayName: "Perfect"}, Progress: &data.GameProgress{Unlocked: 4, Total: 4, Percentage: 100}},
+		{Game: data.Game{ID: 3, DisplayName: "Started"}, Progress: &data.GameProgress{Unlocked: 1, Total: 4, Percentage: 25}},
+	}
+
+	tests := []struct {
+		name           string
+		query          gamesQuery
+		want           []uint64
+		wantIncomplete bool
+	}{
+		{name: "name", query: gamesQuery{Sort: "name", MaxCompletion: 100}, want: []uint64{1, 2, 3}},
+		{name: "perfect", query: gamesQuery{Sort: "name", MaxCompletion: 100, Perfect: true}, want: []uint64{1, 2}, wantIncomplete: true},
+		{name: "completion", query: gamesQuery{Sort: "completion", MaxCompletion: 100}, want: []uint64{2, 3, 1}, wantIncomplete: true},
+		{name: "searched out", query: gamesQuery{Sort: "completion", Search: "perf", MaxCompletion: 100}, want: []uint64{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bag := indexBag{Query: tt.query}
+			bag.Filtered = filterGamesList(games, tt.query)
+
+			got := []uint64{}
+			for _, game := range bag.Filtered {
+				got = append(got, game.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got games %v, want %v", got, tt.want)
+			}
+			if bag.Incomplete() != tt.wantIncomplete {
+				t.Errorf("got incomplete %v, want %v", bag.Incomplete(), tt.wantIncomplete)
+			}
+		})
+	}
+}
+
+func TestParseGamesQuery(t *testing.T) {
+	tests := map[string]gamesQuery{
+		"":                         {Sort: "name", MaxCompletion: 100},
+		"sort=unknown":             {Sort: "name", MaxCompletion: 100},
+		"sort=remaining&q=+portal": {Sort: "remaining", Search: "portal", MaxCompletion: 100},
+		"min=-5&max=150":           {Sort: "name", MaxCompletion: 100},
+		"min=20&max=80":            {Sort: "name", MinCompletion: 20, MaxCompletion: 80},
+		"played-within=3&perfect=true&never-started=true&all=true": {
+			Sort: "name", MaxCompletion: 100, PlayedWithin: 3, Perfect: true, NeverStarted: true, All: true,
+		},
+		"played-within=-1": {Sort: "name", MaxCompletion: 100},
+	}
+
+	for query, want := range tests {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parseGamesQuery(values); got != want {
+			t.Errorf("parseGamesQuery(%q) = %+v, want %+v", query, got, want)
+		}
+	}
+}
+
+func TestFilterGamesList(t *testing.T) {
+	now := time.Now()
+	games := []indexBagGame{
+		{Game: data.Game{ID: 1, DisplayName: "Half-Life", PlaytimeForever: time.Hour * 10, LastPlayed: now.AddDate(-1, 0, 0)}, Progress: &data.GameProgress{Unlocked: 10, Total: 10, Percentage: 100}},
+		{Game: data.Game{ID: 2, DisplayName: "Portal", PlaytimeForever: time.Hour * 5, LastPlayed: now.AddDate(0, 0, -1)}, Progress: &data.GameProgress{Unlocked: 5, Total: 10, Percentage: 50}},
+		{Game: data.Game{ID: 3, DisplayName: "Portal 2", PlaytimeForever: time.Hour * 20, LastPlayed: now.AddDate(0, -2, 0)}, Progress: &data.GameProgress{Total: 50}},
+		{Game: data.Game{ID: 4, DisplayName: "Spacewar", PlaytimeForever: time.Hour}, Progress: &data.GameProgress{}},
+	}
+
+	tests := []struct {
+		name  string
+		query gamesQuery
+		want  []uint64
+	}{
+		{name: "default", query: gamesQuery{Sort: "name", MaxCompletion: 100}, want: []uint64{1, 2, 3}},
+		{name: "all", query: gamesQuery{Sort: "name", MaxCompletion: 100, All: true}, want: []uint64{1, 2, 3, 4}},
+		{name: "search", query: gamesQuery{Sort: "name", Search: "PORTAL", MaxCompletion: 100}, want: []uint64{2, 3}},
+		{name: "completion range", query: gamesQuery{Sort: "name", MinCompletion: 10, MaxCompletion: 90}, want: []uint64{2}},
+		{name: "perfect", query: gamesQuery{Sort: "name", MaxCompletion: 100, Perfect: true}, want: []uint64{1}},
+		{name: "never started", query: gamesQuery{Sort: "name", MaxCompletion: 100, NeverStarted: true, All: true}, want: []uint64{3}},
+		{name: "played within", query: gamesQuery{Sort: "name", MaxCompletion: 100, PlayedWithin: 3}, want: []uint64{2, 3}},
+		{name: "sort completion", query: gamesQuery{Sort: "completion", MaxCompletion: 100, All: true}, want: []uint64{1, 2, 3, 4}},
+		{name: "sort playtime", query: gamesQuery{Sort: "playtime", MaxCompletion: 100}, want: []uint64{3, 1, 2}},
+		{name: "sort last played", query: gamesQuery{Sort: "last-played", MaxCompletion: 100, All: true}, want: []uint64{2, 3, 1, 4}},
+		{name: "sort remaining", query: gamesQuery{Sort: "remaining", MaxCompletion: 100}, want: []uint64{2, 3, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []uint64{}
+			for _, game := range filterGamesList(games, tt.query) {
+				got = append(got, game.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got games %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
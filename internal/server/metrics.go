@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/taiidani/achievements/internal/data"
+)
+
+// metricsHandler exposes the cache metrics in the Prometheus text format.
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	metrics := s.backend.CacheMetrics()
+	families := sortedKeys(metrics.Families)
+	tiers := sortedKeys(metrics.Tiers)
+
+	out := &strings.Builder{}
+	healthy := 0
+	if metrics.Healthy {
+		healthy = 1
+	}
+	fmt.Fprintf(out, "# HELP achievements_cache_healthy Whether the cache backend is reachable.\n# TYPE achievements_cache_healthy gauge\nachievements_cache_healthy %d\n", healthy)
+
+	counter := func(name, help string, value func(family string) uint64) {
+		fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, family := range families {
+			fmt.Fprintf(out, "%s{family=%q} %d\n", name, family, value(family))
+		}
+	}
+
+	counter("achievements_cache_hits_total", "Cache lookups that found a value.", func(f string) uint64 { return metrics.Families[f].Hits })
+	counter("achievements_cache_misses_total", "Cache lookups that found no value.", func(f string) uint64 { return metrics.Families[f].Misses })
+	counter("achievements_cache_sets_total", "Values written to the cache.", func(f string) uint64 { return metrics.Families[f].Sets })
+	counter("achievements_cache_errors_total", "Cache operations that failed.", func(f string) uint64 { return metrics.Families[f].Errors })
+
+	fmt.Fprintf(out, "# HELP achievements_cache_operation_seconds Time spent on cache operations.\n# TYPE achievements_cache_operation_seconds summary\n")
+	for _, family := range families {
+		fmt.Fprintf(out, "achievements_cache_operation_seconds_sum{family=%q} %f\n", family, metrics.Families[family].Latency.Seconds())
+		fmt.Fprintf(out, "achievements_cache_operation_seconds_count{family=%q} %d\n", family, metrics.Families[family].Operations)
+	}
+
+	fmt.Fprintf(out, "# HELP achievements_cache_tier_hits_total Lookups served by each cache tier.\n# TYPE achievements_cache_tier_hits_total counter\n")
+	for _, tier := range tiers {
+		fmt.Fprintf(out, "achievements_cache_tier_hits_total{tier=%q} %d\n", tier, metrics.Tiers[tier].Hits)
+	}
+	fmt.Fprintf(out, "# HELP achievements_cache_tier_misses_total Lookups not served by each cache tier.\n# TYPE achievements_cache_tier_misses_total counter\n")
+	for _, tier := range tiers {
+		fmt.Fprintf(out, "achievements_cache_tier_misses_total{tier=%q} %d\n", tier, metrics.Tiers[tier].Misses)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(out.String()))
+}
+
+type adminBag struct {
+	baseBag
+	Families     []string
+	Tiers        []string
+	CacheMetrics data.CacheMetrics
+}
+
+func (s *Server) adminHandler(w http.ResponseWriter, r *http.Request) {
+	bag := adminBag{baseBag: s.newBag(r, "admin")}
+	if !bag.IsAdmin {
+		errorResponse(w, http.StatusForbidden, fmt.Errorf("this page is only available to administrators"))
+		return
+	}
+
+	bag.CacheMetrics = s.backend.CacheMetrics()
+	bag.Families = sortedKeys(bag.CacheMetrics.Families)
+	bag.Tiers = sortedKeys(bag.CacheMetrics.Tiers)
+
+	renderHtml(w, http.StatusOK, "admin.gohtml", bag)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	ret := make([]string, 0, len(m))
+	for key := range m {
+		ret = append(ret, key)
+	}
+	slices.Sort(ret)
+	return ret
+}
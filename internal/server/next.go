@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data"
+)
+
+// nextLimit is the number of recommendations shown on the next page.
+const nextLimit = 25
+
+type nextBag struct {
+	baseBag
+	SteamID         string
+	User            data.User
+	Months          int
+	Completion      int
+	Recommendations []data.Recommendation
+}
+
+func (s *Server) nextHandler(resp http.ResponseWriter, req *http.Request) {
+	bag := nextBag{baseBag: s.newBag(req, "next")}
+
+	bag.SteamID = req.PathValue("steamid")
+	if len(bag.SteamID) == 0 {
+		errorResponse(resp, http.StatusBadRequest, fmt.Errorf("user ID is required"))
+		return
+	}
+
+	user, err := s.backend.GetUser(req.Context(), bag.SteamID)
+	if err != nil {
+		errorResponse(resp, http.StatusNotFound, fmt.Errorf("could not get user data for %q: %w", bag.SteamID, err))
+		return
+	}
+	bag.User = user
+
+	// Filters are optional, and ignored when invalid
+	opts := []data.RecommendOption{}
+	if months, err := strconv.Atoi(req.URL.Query().Get("months")); err == nil && months > 0 {
+		bag.Months = months
+		opts = append(opts, data.PlayedWithin(time.Hour*24*30*time.Duration(months)))
+	}
+	if completion, err := strconv.Atoi(req.URL.Query().Get("completion")); err == nil && completion > 0 {
+		bag.Completion = completion
+		opts = append(opts, data.MinCompletion(completion))
+	}
+
+	bag.Recommendations, err = s.backend.Recommend(req.Context(), bag.SteamID, nextLimit, opts...)
+	if err != nil {
+		errorResponse(resp, http.StatusNotFound, err)
+		return
+	}
+
+	renderHtml(resp, http.StatusOK, "next.gohtml", bag)
+}
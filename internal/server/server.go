@@ -8,6 +8,8 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/taiidani/achievements/internal/data"
 )
@@ -16,6 +18,7 @@ type Server struct {
 	backend   *data.Data
 	publicURL string
 	port      string
+	admins    []string
 	*http.Server
 }
 
@@ -47,6 +50,12 @@ func NewServer(backend *data.Data) *Server {
 		port:      port,
 		backend:   backend,
 	}
+
+	// Administrators are identified by their Steam ID
+	if admins := os.Getenv("ADMIN_STEAM_IDS"); admins != "" {
+		srv.admins = strings.Split(admins, ",")
+	}
+
 	srv.addRoutes(mux)
 
 	return srv
@@ -55,16 +64,33 @@ func NewServer(backend *data.Data) *Server {
 func (s *Server) addRoutes(mux *http.ServeMux) {
 	mux.Handle("/", s.sessionMiddleware(http.HandlerFunc(s.indexHandler)))
 	mux.Handle("/about", s.sessionMiddleware(http.HandlerFunc(s.aboutHandler)))
+	mux.Handle("/admin", s.sessionMiddleware(http.HandlerFunc(s.adminHandler)))
 	mux.Handle("/assets/", http.HandlerFunc(s.assetsHandler))
+	mux.Handle("/group/{id}", s.sessionMiddleware(http.HandlerFunc(s.groupHandler)))
+	mux.Handle("/group/{id}/game/{gameid}", s.sessionMiddleware(http.HandlerFunc(s.groupGameHandler)))
+	mux.Handle("/groups", s.sessionMiddleware(http.HandlerFunc(s.groupsHandler)))
 	mux.Handle("/hx/user/{steamid}/game/{gameid}/row", s.sessionMiddleware(http.HandlerFunc(s.hxGameRowHandler)))
 	mux.Handle("/hx/user/{steamid}/game/{gameid}/pin", s.sessionMiddleware(http.HandlerFunc(s.hxGamePinHandler)))
+	mux.Handle("/hx/group/{id}/goals", s.sessionMiddleware(http.HandlerFunc(s.hxGroupGoalsHandler)))
+	mux.Handle("/hx/job/{id}", s.sessionMiddleware(http.HandlerFunc(s.hxJobHandler)))
+	mux.Handle("/hx/user/{steamid}/goals", s.sessionMiddleware(http.HandlerFunc(s.hxUserGoalsHandler)))
+	mux.Handle("/hx/user/{steamid}/refresh", s.sessionMiddleware(http.HandlerFunc(s.hxUserRefreshHandler)))
+	mux.Handle("/hx/user/{steamid}/score", s.sessionMiddleware(http.HandlerFunc(s.hxUserScoreHandler)))
+	mux.Handle("/hx/user/{steamid}/timeline", s.sessionMiddleware(http.HandlerFunc(s.hxTimelineHandler)))
+	mux.Handle("/user/{steamid}/export", s.sessionMiddleware(http.HandlerFunc(s.exportHandler)))
 	mux.Handle("/user/{steamid}/games", s.sessionMiddleware(http.HandlerFunc(s.gamesHandler)))
 	mux.Handle("/user/{steamid}/game/{gameid}", s.sessionMiddleware(http.HandlerFunc(s.gameHandler)))
+	mux.Handle("/user/{steamid}/goals", s.sessionMiddleware(http.HandlerFunc(s.goalsHandler)))
+	mux.Handle("/user/{steamid}/next", s.sessionMiddleware(http.HandlerFunc(s.nextHandler)))
+	mux.Handle("/user/{steamid}/stats", s.sessionMiddleware(http.HandlerFunc(s.statsHandler)))
+	mux.Handle("/user/{steamid}/timeline", s.sessionMiddleware(http.HandlerFunc(s.timelineHandler)))
+	mux.Handle("/user/{steamid}/webhooks", s.sessionMiddleware(http.HandlerFunc(s.webhooksHandler)))
 	mux.Handle("/user/login", s.sessionMiddleware(http.HandlerFunc(s.userLoginHandler)))
 	mux.Handle("/user/login/steam", s.sessionMiddleware(http.HandlerFunc(s.userLoginSteamHandler)))
 	mux.Handle("/user/change", s.sessionMiddleware(http.HandlerFunc(s.userChangeHandler)))
 	mux.Handle("/user/logout", http.HandlerFunc(s.userLogoutHandler))
 	mux.Handle("/user/lookup", http.HandlerFunc(s.userLookupHandler))
+	mux.Handle("/metrics", http.HandlerFunc(s.metricsHandler))
 }
 
 func renderHtml(writer http.ResponseWriter, code int, file string, data any) {
@@ -94,6 +120,7 @@ type baseBag struct {
 	SessionKey  string
 	Session     *data.Session
 	SessionUser *data.User
+	IsAdmin     bool
 	Page        string
 }
 
@@ -116,6 +143,7 @@ func (s *Server) newBag(r *http.Request, pageName string) baseBag {
 				log.Warn("Unable to load session user", "steam-id", sess.SteamID, "error", err)
 			}
 			ret.SessionUser = &user
+			ret.IsAdmin = slices.Contains(s.admins, sess.SteamID)
 		}
 	}
 
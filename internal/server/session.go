@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 
 	"github.com/google/uuid"
 )
@@ -21,6 +22,13 @@ func (s *Server) sessionMiddleware(next http.Handler) http.Handler {
 			r.Header.Del(steamIDHeaderKey)
 		}
 
+		// Sessions created before their cookie was marked SameSite are
+		// still sent cross-site, so changes must come from this site
+		if isCrossSiteChange(r) {
+			errorResponse(w, http.StatusForbidden, fmt.Errorf("cross-site requests may not make changes"))
+			return
+		}
+
 		// Is the user ID in the session?
 		cookie, err := r.Cookie("session")
 		if err == nil {
@@ -40,3 +48,23 @@ func (s *Server) buildSessionKey() string {
 	key := uuid.New()
 	return key.String()
 }
+
+// isCrossSiteChange reports whether the request would change state and was
+// made by another site, as reported by the browser.
+func isCrossSiteChange(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		return site == "cross-site"
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/taiidani/achievements/internal/data"
+)
+
+type statsBag struct {
+	baseBag
+	SteamID string
+	User    data.User
+	Stats   data.UserStats
+	Months  []statsBagMonth
+}
+
+type statsBagMonth struct {
+	data.MonthUnlocks
+	// Height is the percentage of the chart that the month's bar fills,
+	// relative to the busiest month.
+	Height int
+}
+
+func (s *Server) statsHandler(resp http.ResponseWriter, req *http.Request) {
+	bag := statsBag{baseBag: s.newBag(req, "stats")}
+
+	bag.SteamID = req.PathValue("steamid")
+	if len(bag.SteamID) == 0 {
+		errorResponse(resp, http.StatusBadRequest, fmt.Errorf("user ID is required"))
+		return
+	}
+
+	user, err := s.backend.GetUser(req.Context(), bag.SteamID)
+	if err != nil {
+		errorResponse(resp, http.StatusNotFound, fmt.Errorf("could not get user data for %q: %w", bag.SteamID, err))
+		return
+	}
+	bag.User = user
+
+	bag.Stats, err = s.backend.GetUserStats(req.Context(), bag.SteamID)
+	if err != nil {
+		errorResponse(resp, http.StatusNotFound, err)
+		return
+	}
+
+	busiest := 0
+	for _, month := range bag.Stats.UnlocksPerMonth {
+		busiest = max(busiest, month.Count)
+	}
+	for _, month := range bag.Stats.UnlocksPerMonth {
+		m := statsBagMonth{MonthUnlocks: month}
+		if busiest > 0 {
+			m.Height = month.Count * 100 / busiest
+		}
+		bag.Months = append(bag.Months, m)
+	}
+
+	renderHtml(resp, http.StatusOK, "stats.gohtml", bag)
+}
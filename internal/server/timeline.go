@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/taiidani/achievements/internal/data"
+)
+
+// timelinePageSize is the number of unlocks rendered per page of the timeline,
+// before the next page is loaded by scrolling.
+const timelinePageSize = 50
+
+type timelineBag struct {
+	baseBag
+	SteamID string
+	User    data.User
+	timelinePage
+}
+
+type hxTimelineBag struct {
+	baseBag
+	SteamID string
+	timelinePage
+}
+
+type timelinePage struct {
+	Days []timelineDay
+	// Next is the Unix time to load the following page before, or 0 if this
+	// is the last page.
+	Next int64
+}
+
+type timelineDay struct {
+	Date time.Time
+	// Continued is set when the day began on the previous page, and so
+	// already has a heading.
+	Continued bool
+	Unlocks   []data.Unlock
+}
+
+func (s *Server) timelineHandler(resp http.ResponseWriter, req *http.Request) {
+	bag := timelineBag{baseBag: s.newBag(req, "timeline")}
+
+	bag.SteamID = req.PathValue("steamid")
+	if len(bag.SteamID) == 0 {
+		errorResponse(resp, http.StatusBadRequest, fmt.Errorf("user ID is required"))
+		return
+	}
+
+	user, err := s.backend.GetUser(req.Context(), bag.SteamID)
+	if err != nil {
+		errorResponse(resp, http.StatusNotFound, fmt.Errorf("could not get user data for %q: %w", bag.SteamID, err))
+		return
+	}
+	bag.User = user
+
+	bag.timelinePage, err = s.loadTimelinePage(req.Context(), bag.SteamID, time.Time{})
+	if err != nil {
+		errorResponse(resp, http.StatusNotFound, err)
+		return
+	}
+
+	renderHtml(resp, http.StatusOK, "timeline.gohtml", bag)
+}
+
+func (s *Server) hxTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	bag := hxTimelineBag{baseBag: s.newBag(r, "")}
+
+	bag.SteamID = r.PathValue("steamid")
+	if len(bag.SteamID) == 0 {
+		errorResponse(w, http.StatusBadRequest, fmt.Errorf("user ID is required"))
+		return
+	}
+
+	before, _ := strconv.ParseInt(r.URL.Query().Get("before"), 10, 64)
+	if before <= 0 {
+		errorResponse(w, http.StatusBadRequest, fmt.Errorf("invalid before time provided"))
+		return
+	}
+
+	var err error
+	bag.timelinePage, err = s.loadTimelinePage(r.Context(), bag.SteamID, time.Unix(before, 0))
+	if err != nil {
+		errorResponse(w, http.StatusNotFound, err)
+		return
+	}
+
+	renderHtml(w, http.StatusOK, "timeline-days.gohtml", bag)
+}
+
+// loadTimelinePage groups a page of the user's unlocks made before the given
+// time by day. A zero before loads the first page.
+func (s *Server) loadTimelinePage(ctx context.Context, steamID string, before time.Time) (timelinePage, error) {
+	ret := timelinePage{Days: []timelineDay{}}
+
+	unlocks, err := s.backend.GetTimeline(ctx, steamID, time.Time{}, before)
+	if err != nil {
+		return ret, err
+	}
+
+	// Keep unlocks from the same second together, as the next page begins
+	// strictly before the last unlock shown
+	size := min(timelinePageSize, len(unlocks))
+	for size < len(unlocks) && unlocks[size].UnlockedOn.Equal(unlocks[size-1].UnlockedOn) {
+		size++
+	}
+	if size < len(unlocks) {
+		ret.Next = unlocks[size-1].UnlockedOn.Unix()
+	}
+
+	for _, unlock := range unlocks[:size] {
+		on := unlock.UnlockedOn
+		date := time.Date(on.Year(), on.Month(), on.Day(), 0, 0, 0, 0, on.Location())
+
+		if len(ret.Days) == 0 || !ret.Days[len(ret.Days)-1].Date.Equal(date) {
+			ret.Days = append(ret.Days, timelineDay{
+				Date:      date,
+				Continued: len(ret.Days) == 0 && !before.IsZero() && sameDay(before, date),
+			})
+		}
+
+		day := &ret.Days[len(ret.Days)-1]
+		day.Unlocks = append(day.Unlocks, unlock)
+	}
+
+	return ret, nil
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -94,12 +95,24 @@ func (s *Server) userLoginSteamHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Webhooks and goals expire along with sessions, so are kept for as
+	// long as the newest one
+	if err := s.backend.RenewWebhooks(r.Context(), steamID); err != nil {
+		slog.Warn("Unable to renew webhooks", "steam-id", steamID, "error", err)
+	}
+	if err := s.backend.RenewGoals(r.Context(), steamID); err != nil {
+		slog.Warn("Unable to renew goals", "steam-id", steamID, "error", err)
+	}
+
 	cookie := http.Cookie{
 		Name:     "session",
 		Value:    sessionKey,
 		Secure:   !DevMode,
 		Path:     "/",
 		HttpOnly: true,
+		// Cross-site requests are sent without the session, so that other
+		// sites cannot submit forms on the user's behalf
+		SameSite: http.SameSiteLaxMode,
 		MaxAge:   int(data.DefaultSessionExpiration.Seconds()),
 	}
 	http.SetCookie(w, &cookie)
@@ -108,10 +121,11 @@ func (s *Server) userLoginSteamHandler(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) userLogoutHandler(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
-		Name:    "session",
-		Value:   "",
-		Path:    "/",
-		Expires: time.Unix(0, 0),
+		Name:     "session",
+		Value:    "",
+		Path:     "/",
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Unix(0, 0),
 	})
 
 	slog.Info("User logged out")
@@ -125,22 +139,32 @@ func (s *Server) userLookupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Lookup the user, confirming their Steam ID
-	_, err := s.backend.GetUser(r.Context(), steamID)
+	user, err := s.resolveUser(r.Context(), steamID)
 	if err != nil {
-		// Attempt to resolve the user's vanity URL into an ID
-		steamID, err = s.backend.ResolveVanityURL(r.Context(), steamID)
-		if err != nil {
-			errorResponse(w, http.StatusNotFound, fmt.Errorf("could not resolve user id %q to a Steam User ID or Vanity URL: %w", steamID, err))
-			return
-		}
+		errorResponse(w, http.StatusNotFound, err)
+		return
+	}
 
-		_, err = s.backend.GetUser(r.Context(), steamID)
-		if err != nil {
-			errorResponse(w, http.StatusNotFound, fmt.Errorf("could not get user data for %q: %w", steamID, err))
-			return
-		}
+	http.Redirect(w, r, fmt.Sprintf("/user/%s/games", user.SteamID), http.StatusTemporaryRedirect)
+}
+
+// resolveUser looks up a user by their Steam ID or vanity URL name.
+func (s *Server) resolveUser(ctx context.Context, steamID string) (data.User, error) {
+	// Lookup the user, confirming their Steam ID
+	user, err := s.backend.GetUser(ctx, steamID)
+	if err == nil {
+		return user, nil
+	}
+
+	// Attempt to resolve the user's vanity URL into an ID
+	resolved, err := s.backend.ResolveVanityURL(ctx, steamID)
+	if err != nil {
+		return data.User{}, fmt.Errorf("could not resolve user id %q to a Steam User ID or Vanity URL: %w", steamID, err)
 	}
 
-	http.Redirect(w, r, fmt.Sprintf("/user/%s/games", steamID), http.StatusTemporaryRedirect)
+	user, err = s.backend.GetUser(ctx, resolved)
+	if err != nil {
+		return data.User{}, fmt.Errorf("could not get user data for %q: %w", resolved, err)
+	}
+	return user, nil
 }
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/taiidani/achievements/internal/data"
+)
+
+type webhooksBag struct {
+	baseBag
+	SteamID    string
+	User       data.User
+	Webhooks   []data.Webhook
+	Deliveries []data.WebhookDelivery
+	Formats    []data.WebhookFormat
+	// DeliversUnlocks is unset when new unlocks cannot be detected, leaving
+	// only milestones to be delivered.
+	DeliversUnlocks bool
+	// Error reports why the submitted change could not be made.
+	Error error
+}
+
+func (s *Server) webhooksHandler(resp http.ResponseWriter, req *http.Request) {
+	bag := webhooksBag{baseBag: s.newBag(req, "webhooks"), Formats: data.WebhookFormats}
+
+	bag.SteamID = req.PathValue("steamid")
+	if len(bag.SteamID) == 0 {
+		errorResponse(resp, http.StatusBadRequest, fmt.Errorf("user ID is required"))
+		return
+	}
+
+	// Webhooks are private to the user that configured them
+	if bag.Session == nil || bag.Session.SteamID != bag.SteamID {
+		errorResponse(resp, http.StatusForbidden, fmt.Errorf("only the logged in user may manage their webhooks"))
+		return
+	}
+	bag.User = *bag.SessionUser
+
+	code := http.StatusOK
+	if req.Method == http.MethodPost {
+		var err error
+		switch req.FormValue("action") {
+		case "add":
+			_, err = s.backend.AddWebhook(req.Context(), bag.SteamID, req.FormValue("url"), data.WebhookFormat(req.FormValue("format")))
+		case "delete":
+			err = s.backend.DeleteWebhook(req.Context(), bag.SteamID, req.FormValue("id"))
+		case "test":
+			_, err = s.backend.TestWebhook(req.Context(), bag.SteamID, req.FormValue("id"))
+		default:
+			err = fmt.Errorf("unknown action %q", req.FormValue("action"))
+		}
+
+		if err == nil {
+			http.Redirect(resp, req, req.URL.Path, http.StatusSeeOther)
+			return
+		}
+		bag.Error = err
+		code = http.StatusBadRequest
+	}
+
+	var err error
+	bag.Webhooks, err = s.backend.GetWebhooks(req.Context(), bag.SteamID)
+	if err != nil {
+		errorResponse(resp, http.StatusInternalServerError, err)
+		return
+	}
+
+	bag.Deliveries, err = s.backend.GetWebhookDeliveries(req.Context(), bag.SteamID)
+	if err != nil {
+		errorResponse(resp, http.StatusInternalServerError, err)
+		return
+	}
+	bag.DeliversUnlocks = s.backend.RecordsHistory()
+
+	renderHtml(resp, code, "webhooks.gohtml", bag)
+}
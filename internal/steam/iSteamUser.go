@@ -116,5 +116,11 @@ func (c *iSteamUserService) ResolveVanityURL(ctx context.Context, vanityURL stri
 		return nil, fmt.Errorf("unable to parse response: %w", err)
 	}
 
+	// A successful lookup is reported with a success value of 1. Anything else
+	// (typically 42) means that no user has claimed the vanity name.
+	if ret.Response.Success != 1 {
+		return nil, fmt.Errorf("no match for vanity URL %q: %w", vanityURL, ErrNotFound)
+	}
+
 	return ret, err
 }
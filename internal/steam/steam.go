@@ -1,11 +1,16 @@
 package steam
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Client struct {
@@ -13,6 +18,7 @@ type Client struct {
 	ISteamApps      *iSteamAppsService
 	ISteamUser      *iSteamUserService
 	ISteamUserStats *iSteamUserStatsService
+	Store           *storeService
 }
 
 type service struct {
@@ -36,6 +42,7 @@ func NewClient() *Client {
 		ISteamApps:      newISteamAppsService(svc),
 		ISteamUser:      newISteamUserService(svc),
 		ISteamUserStats: newISteamUserStatsService(svc),
+		Store:           newStoreService(svc),
 	}
 }
 
@@ -51,6 +58,63 @@ func (s *service) url(api string, method string, version string, values url.Valu
 	return ret
 }
 
+// Errors that Steam responses are classified into. Use errors.Is to test for
+// them, as they are wrapped in an *APIError alongside the response details.
+var (
+	// ErrNotFound indicates that the requested resource does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrPrivateProfile indicates that the user's profile or game details are
+	// not public.
+	ErrPrivateProfile = errors.New("profile is not public")
+	// ErrNoStats indicates that the requested app does not publish stats or
+	// achievements.
+	ErrNoStats = errors.New("requested app has no stats")
+	// ErrRateLimited indicates that too many requests have been made, and that
+	// no more should be made until the error's RetryAfter has passed.
+	ErrRateLimited = errors.New("rate limited")
+)
+
+// APIError describes a non-OK response from the Steam API.
+type APIError struct {
+	StatusCode int
+	// Message is the error reported in the response body, if any.
+	Message string
+	// RetryAfter is how long the response asked to wait before retrying, or 0
+	// if it did not say.
+	RetryAfter time.Duration
+	kind       error
+}
+
+func (e *APIError) Error() string {
+	var ret string
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		ret = "endpoint not found"
+	case http.StatusMethodNotAllowed:
+		ret = "method not allowed"
+	case http.StatusBadGateway:
+		ret = "bad gateway"
+	case http.StatusBadRequest:
+		ret = "bad request"
+	case http.StatusForbidden:
+		ret = "forbidden"
+	case http.StatusTooManyRequests:
+		ret = "too many requests"
+	default:
+		ret = fmt.Sprintf("unknown API response %d", e.StatusCode)
+	}
+
+	if e.Message != "" {
+		ret += ": " + e.Message
+	}
+	return ret
+}
+
+// Unwrap exposes the classification of the error, if known.
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
 func (s *service) httpError(resp *http.Response, err error) error {
 	if err != nil {
 		return fmt.Errorf("could not submit request: %w", err)
@@ -61,23 +125,65 @@ func (s *service) httpError(resp *http.Response, err error) error {
 	}
 
 	// Non-OK response. Let's grab the body to troubleshoot
-	_, _ = io.Copy(os.Stderr, resp.Body)
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	_, _ = os.Stderr.Write(body)
 
-	var ret error
-	switch resp.StatusCode {
-	case http.StatusNotFound:
-		ret = fmt.Errorf("endpoint not found")
-	case http.StatusMethodNotAllowed:
-		ret = fmt.Errorf("method not allowed")
-	case http.StatusBadGateway:
-		ret = fmt.Errorf("bad gateway")
-	case http.StatusBadRequest:
-		ret = fmt.Errorf("bad request")
-	case http.StatusForbidden:
-		ret = fmt.Errorf("forbidden")
-	default:
-		ret = fmt.Errorf("unknown API response %d", resp.StatusCode)
+	ret := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    errorMessage(body),
+		RetryAfter: retryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+
+	// Steam reports most failures as a generic status code, with the
+	// specifics only present in the message
+	switch {
+	case strings.Contains(ret.Message, "not public"):
+		ret.kind = ErrPrivateProfile
+	case strings.Contains(ret.Message, "no stats"):
+		ret.kind = ErrNoStats
+	case resp.StatusCode == http.StatusNotFound:
+		ret.kind = ErrNotFound
+	case resp.StatusCode == http.StatusTooManyRequests:
+		ret.kind = ErrRateLimited
 	}
 
 	return ret
 }
+
+// retryAfter parses a Retry-After header, given either as a number of seconds
+// or as the time to retry at.
+func retryAfter(header string, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// errorMessage extracts the error message from a Steam error response, which
+// may be nested underneath the endpoint's top level key.
+//
+// For example: {"playerstats":{"error":"Requested app has no stats","success":false}}
+func errorMessage(body []byte) string {
+	type errorBody struct {
+		Error string `json:"error"`
+	}
+
+	flat := errorBody{}
+	if err := json.Unmarshal(body, &flat); err == nil && flat.Error != "" {
+		return flat.Error
+	}
+
+	nested := map[string]errorBody{}
+	if err := json.Unmarshal(body, &nested); err == nil {
+		for _, v := range nested {
+			if v.Error != "" {
+				return v.Error
+			}
+		}
+	}
+
+	return ""
+}
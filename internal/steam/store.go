@@ -0,0 +1,160 @@
+package steam
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// storeService queries the unofficial Steam storefront API, which describes
+// apps as they are shown on the store and does not require an API key.
+//
+// The storefront allows about 200 requests every 5 minutes, so requests are
+// throttled to that rate and held back entirely once it reports that the limit
+// has been reached.
+type storeService struct {
+	*service
+	mx sync.Mutex
+	// tat is the theoretical arrival time of the next request, had every
+	// request been sent exactly one storeInterval apart.
+	tat time.Time
+}
+
+const storeAPIHost = "store.steampowered.com"
+
+const (
+	// storeInterval is the average time between storefront requests.
+	storeInterval = time.Second * 3 / 2
+	// storeBurst is the number of requests that may be sent at once, after
+	// the storefront has not been used for a while.
+	storeBurst = 20
+	// storeMaxWait is the longest a request waits for its turn before failing
+	// as rate limited, rather than holding up its caller.
+	storeMaxWait = time.Second * 5
+	// storeBackOff is how long requests are held back for when the storefront
+	// rate limits a request without saying for how long.
+	storeBackOff = time.Minute
+)
+
+func newStoreService(service *service) *storeService {
+	return &storeService{
+		service: service,
+	}
+}
+
+// wait blocks until the next request may be sent, failing with ErrRateLimited
+// if that would take longer than storeMaxWait.
+func (c *storeService) wait(ctx context.Context) error {
+	c.mx.Lock()
+	now := time.Now()
+	tat := c.tat
+	if tat.Before(now) {
+		tat = now
+	}
+
+	delay := tat.Add(-(storeBurst - 1) * storeInterval).Sub(now)
+	if delay > storeMaxWait {
+		c.mx.Unlock()
+		return fmt.Errorf("storefront requests are throttled for %s: %w", delay.Round(time.Second), ErrRateLimited)
+	}
+	c.tat = tat.Add(storeInterval)
+	c.mx.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backOff holds back every request for the given duration.
+func (c *storeService) backOff(d time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	tat := time.Now().Add(d + (storeBurst-1)*storeInterval)
+	if tat.After(c.tat) {
+		c.tat = tat
+	}
+}
+
+type AppDetails struct {
+	Type   string  `json:"type"`
+	Name   string  `json:"name"`
+	AppID  uint64  `json:"steam_appid"`
+	Genres []Genre `json:"genres"`
+}
+
+type Genre struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+func (c *storeService) GetAppDetails(ctx context.Context, appID uint64) (*AppDetails, error) {
+	query := url.Values{}
+	query.Add("appids", fmt.Sprintf("%d", appID))
+	target := url.URL{
+		Scheme:   steamAPIScheme,
+		Host:     storeAPIHost,
+		Path:     "/api/appdetails",
+		RawQuery: query.Encode(),
+	}
+	slog.DebugContext(ctx, "URL formed", "url", target.String())
+
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not format request: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if resp != nil && resp.Close {
+		defer resp.Body.Close()
+	}
+	if err := c.httpError(resp, err); err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && errors.Is(apiErr, ErrRateLimited) {
+			c.backOff(cmp.Or(apiErr.RetryAfter, storeBackOff))
+		}
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+
+	respBody := &strings.Builder{}
+	_, _ = io.Copy(respBody, resp.Body)
+
+	// Responses are keyed by app ID, as several may be requested at once
+	body := map[string]struct {
+		Success bool       `json:"success"`
+		Data    AppDetails `json:"data"`
+	}{}
+	err = json.Unmarshal([]byte(respBody.String()), &body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse response: %w", err)
+	}
+
+	ret, ok := body[fmt.Sprintf("%d", appID)]
+	if !ok || !ret.Success {
+		return nil, fmt.Errorf("no store details for app %d: %w", appID, ErrNotFound)
+	}
+
+	return &ret.Data, nil
+}
@@ -2,16 +2,21 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/taiidani/achievements/internal/data"
 	"github.com/taiidani/achievements/internal/data/cache"
+	"github.com/taiidani/achievements/internal/data/history"
 	"github.com/taiidani/achievements/internal/server"
 	"github.com/taiidani/achievements/internal/steam"
 )
@@ -22,45 +27,281 @@ func main() {
 
 	slog.SetLogLoggerLevel(slog.LevelDebug)
 
+	cache.Namespace = os.Getenv("CACHE_NAMESPACE")
+
+	// Run any subcommand instead of serving
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Define external clients
 	client := steam.NewClient()
 	// cache := cache.NewFile()
-	cache, err := setupCache()
+	cache, err := setupCache(ctx)
 	if err != nil {
 		log.Fatal("Unable to set up cache", "error", err)
 	}
+	cache = instrumentCache(cache)
+
+	history, err := setupHistory(cache)
+	if err != nil {
+		log.Fatal("Unable to set up history", "error", err)
+	}
+	if history != nil {
+		defer history.Close()
+	}
+
+	backend := data.NewData(client, cache, history)
+	if name := os.Getenv("SCORER"); name != "" {
+		scorer, err := data.ParseScorer(name)
+		if err != nil {
+			log.Fatal("Unable to set up scorer", "error", err)
+		}
+		backend.SetScorer(scorer)
+	}
+
+	setupJobs(ctx, cache, backend)
 
 	// Begin refreshing data
-	go data.Refresher(ctx, client, cache)
+	go data.NewRefresher(backend, setupRefresherOptions()).Run(ctx)
 
 	// Serve until interrupted
-	if err := serve(ctx, client, cache); err != nil {
+	if err := serve(ctx, backend); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func setupCache() (cache.Cache, error) {
-	if addr, ok := os.LookupEnv("REDIS_ADDR"); ok {
-		return cache.NewRedis(addr), nil
+func setupCache(ctx context.Context) (cache.Cache, error) {
+	redisOpts, ok, err := setupRedisOptions()
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		slog.Warn("No Redis configuration env vars set. Falling back upon in-memory store")
+		return cache.NewMemory(), nil
+	}
+
+	redis := cache.NewRedis(redisOpts)
+
+	encoding, err := setupCacheEncoding()
+	if err != nil {
+		return nil, err
+	}
+	redis.SetEncoding(encoding)
+
+	// Front Redis with a small in-process cache for hot keys
+	opts := cache.TieredOptions{
+		L1Size:              1000,
+		L1TTL:               time.Minute,
+		InvalidationChannel: os.Getenv("CACHE_INVALIDATION_CHANNEL"),
+	}
+	if size, err := strconv.Atoi(os.Getenv("CACHE_L1_SIZE")); err == nil {
+		opts.L1Size = size
+	}
+	if ttl, err := time.ParseDuration(os.Getenv("CACHE_L1_TTL")); err == nil {
+		opts.L1TTL = ttl
+	}
+
+	tiered := cache.NewTiered(redis, opts)
+	go tiered.Listen(ctx)
+
+	return tiered, nil
+}
+
+// instrumentCache records metrics for every key family stored by the webapp.
+func instrumentCache(c cache.Cache) cache.Cache {
+	return cache.NewInstrumented(c, data.KeyFamilies())
+}
+
+// findCache returns the first cache of type T in the chain of caches wrapping
+// one another, starting with c.
+func findCache[T any](c cache.Cache) (T, bool) {
+	for c != nil {
+		if ret, ok := c.(T); ok {
+			return ret, true
+		}
+
+		unwrapper, ok := c.(interface{ Unwrap() cache.Cache })
+		if !ok {
+			break
+		}
+		c = unwrapper.Unwrap()
+	}
+
+	var zero T
+	return zero, false
+}
+
+// setupJobs runs background jobs through the queue of the cache backend, with
+// JOB_WORKERS workers.
+func setupJobs(ctx context.Context, c cache.Cache, backend *data.Data) {
+	queue, ok := findCache[cache.Queue](c)
+	if !ok {
+		slog.Warn("Cache does not support queues. Background jobs are disabled")
+		return
+	}
+
+	jobs := data.NewJobs(c, queue)
+	backend.SetJobs(jobs)
+
+	workers := 2
+	if n, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && n > 0 {
+		workers = n
+	}
+	go jobs.Run(ctx, workers)
+}
+
+// setupHistory opens the store that achievement unlocks are recorded in. A
+// SQLite file is used when HISTORY_SQLITE_PATH is set, otherwise history is
+// kept alongside the cache in Redis. Without either, no history is recorded.
+func setupHistory(c cache.Cache) (history.Store, error) {
+	if path := os.Getenv("HISTORY_SQLITE_PATH"); path != "" {
+		return history.NewSQLite(path)
+	}
+
+	if redis, ok := findCache[*cache.Redis](c); ok {
+		return history.NewRedis(redis.Client()), nil
+	}
+
+	slog.Warn("No history store configured. Achievement unlock history will not be recorded")
+	return nil, nil
+}
+
+// setupRedisOptions builds the Redis configuration from the environment,
+// reporting false if Redis has not been configured at all.
+func setupRedisOptions() (cache.RedisOptions, bool, error) {
+	opts := cache.RedisOptions{}
+	useTLS := false
+
+	if url, ok := os.LookupEnv("REDIS_URL"); ok {
+		var err error
+		opts, err = cache.ParseRedisURL(url)
+		if err != nil {
+			return opts, false, fmt.Errorf("invalid REDIS_URL: %w", err)
+		}
+		useTLS = opts.TLS != nil
+	} else if addrs, ok := os.LookupEnv("REDIS_SENTINEL_ADDRS"); ok {
+		opts.Addrs = strings.Split(addrs, ",")
+		opts.MasterName = os.Getenv("REDIS_SENTINEL_MASTER")
+		opts.SentinelPassword = os.Getenv("REDIS_SENTINEL_PASSWORD")
+		if opts.MasterName == "" {
+			return opts, false, fmt.Errorf("REDIS_SENTINEL_MASTER is required alongside REDIS_SENTINEL_ADDRS")
+		}
+	} else if addrs, ok := os.LookupEnv("REDIS_CLUSTER_ADDRS"); ok {
+		opts.Addrs = strings.Split(addrs, ",")
+		opts.Cluster = true
+	} else if addr, ok := os.LookupEnv("REDIS_ADDR"); ok {
+		opts.Addrs = []string{addr}
 	} else if host, ok := os.LookupEnv("REDIS_HOST"); ok {
-		db := 0
-		if dbParsed, err := strconv.ParseInt(os.Getenv("REDIS_DB"), 10, 64); err == nil {
-			db = int(dbParsed)
+		opts.Addrs = []string{fmt.Sprintf("%s:%s", host, os.Getenv("REDIS_PORT"))}
+		useTLS = true
+	} else {
+		return opts, false, nil
+	}
+
+	// Credentials in the URL take precedence over these
+	if user, ok := os.LookupEnv("REDIS_USER"); ok && opts.Username == "" {
+		opts.Username = user
+	}
+	if pass, ok := os.LookupEnv("REDIS_PASSWORD"); ok && opts.Password == "" {
+		opts.Password = pass
+	}
+	if db, err := strconv.Atoi(os.Getenv("REDIS_DB")); err == nil {
+		opts.DB = db
+	}
+
+	if tlsEnv, ok := os.LookupEnv("REDIS_TLS"); ok {
+		useTLS = tlsEnv == "true"
+	}
+	if useTLS {
+		if opts.TLS == nil {
+			opts.TLS = &tls.Config{}
+		}
+
+		if caFile, ok := os.LookupEnv("REDIS_TLS_CA_FILE"); ok {
+			ca, err := os.ReadFile(caFile)
+			if err != nil {
+				return opts, false, fmt.Errorf("unable to read REDIS_TLS_CA_FILE: %w", err)
+			}
+
+			opts.TLS.RootCAs = x509.NewCertPool()
+			if !opts.TLS.RootCAs.AppendCertsFromPEM(ca) {
+				return opts, false, fmt.Errorf("no certificates found in REDIS_TLS_CA_FILE %q", caFile)
+			}
+		}
+
+		// Only intended for local development against self-signed certificates
+		if os.Getenv("REDIS_TLS_INSECURE_SKIP_VERIFY") == "true" {
+			slog.Warn("Redis TLS certificate verification is disabled")
+			opts.TLS.InsecureSkipVerify = true
+		}
+	} else {
+		opts.TLS = nil
+	}
+
+	return opts, true, nil
+}
+
+// setupCacheEncoding configures how values are written to Redis. Entries
+// written with any other encoding remain readable, so these may be changed
+// freely between deploys.
+func setupCacheEncoding() (cache.Encoding, error) {
+	ret := cache.DefaultEncoding
+
+	if name, ok := os.LookupEnv("CACHE_CODEC"); ok {
+		codec, err := cache.ParseCodec(name)
+		if err != nil {
+			return ret, fmt.Errorf("invalid CACHE_CODEC: %w", err)
+		}
+		ret.Codec = codec
+	}
+
+	if name, ok := os.LookupEnv("CACHE_COMPRESSION"); ok {
+		compression, err := cache.ParseCompression(name)
+		if err != nil {
+			return ret, fmt.Errorf("invalid CACHE_COMPRESSION: %w", err)
 		}
+		ret.Compression = compression
+	}
+
+	if size, err := strconv.Atoi(os.Getenv("CACHE_COMPRESS_ABOVE")); err == nil {
+		ret.CompressAbove = size
+	}
+
+	return ret, nil
+}
 
-		port := os.Getenv("REDIS_PORT")
-		user := os.Getenv("REDIS_USER")
-		pass := os.Getenv("REDIS_PASSWORD")
+// setupRefresherOptions configures the background refresher from the
+// environment, falling back upon the defaults for anything unset or invalid.
+func setupRefresherOptions() data.RefresherOptions {
+	ret := data.DefaultRefresherOptions
 
-		return cache.NewRedisSecureCache(host, port, user, pass, db), nil
+	durations := map[string]*time.Duration{
+		"REFRESH_ACTIVE_WITHIN":   &ret.ActiveWithin,
+		"REFRESH_USER_INTERVAL":   &ret.UserInterval,
+		"REFRESH_GLOBAL_INTERVAL": &ret.GlobalInterval,
+		"REFRESH_SCHEMA_INTERVAL": &ret.SchemaInterval,
+	}
+	for env, d := range durations {
+		if val, err := time.ParseDuration(os.Getenv(env)); err == nil {
+			*d = val
+		}
+	}
+
+	if workers, err := strconv.Atoi(os.Getenv("REFRESH_WORKERS")); err == nil && workers > 0 {
+		ret.Workers = workers
+	}
+	if jitter, err := strconv.ParseFloat(os.Getenv("REFRESH_JITTER"), 64); err == nil && jitter >= 0 && jitter < 1 {
+		ret.Jitter = jitter
 	}
 
-	slog.Warn("No REDIS_ADDR or REDIS_HOST env var set. Falling back upon in-memory store")
-	return cache.NewMemory(), nil
+	return ret
 }
 
-func serve(ctx context.Context, client *steam.Client, cache cache.Cache) error {
-	backend := data.NewData(client, cache)
+func serve(ctx context.Context, backend *data.Data) error {
 	srv := server.NewServer(backend)
 
 	go func() {